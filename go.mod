@@ -5,9 +5,13 @@ go 1.20
 require (
 	cloud.google.com/go v0.110.2
 	cloud.google.com/go/spanner v1.46.0
+	github.com/fsnotify/fsnotify v1.6.0
 	github.com/goccy/go-yaml v1.11.0
 	github.com/google/go-cmp v0.5.9
+	google.golang.org/api v0.118.0
 	google.golang.org/genproto v0.0.0-20230410155749-daa745c078e1
+	google.golang.org/grpc v1.55.0
+	google.golang.org/protobuf v1.30.0
 )
 
 require (
@@ -34,8 +38,5 @@ require (
 	golang.org/x/sys v0.7.0 // indirect
 	golang.org/x/text v0.9.0 // indirect
 	golang.org/x/xerrors v0.0.0-20220907171357-04be3eba64a2 // indirect
-	google.golang.org/api v0.118.0 // indirect
 	google.golang.org/appengine v1.6.7 // indirect
-	google.golang.org/grpc v1.55.0 // indirect
-	google.golang.org/protobuf v1.30.0 // indirect
 )
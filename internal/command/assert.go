@@ -0,0 +1,89 @@
+package command
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kauche/splanter/internal/spanner"
+)
+
+func assert(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("assert", flag.ExitOnError)
+	dbFlags := registerDBFlags(fs)
+	directory := fs.String("directory", "", "Directory contains expectation yaml files")
+	loaderFlags := registerLoaderFlags(fs)
+
+	fs.Parse(args)
+
+	if err := dbFlags.validate(); err != nil {
+		fmt.Fprint(os.Stderr, err.Error())
+		return 1
+	}
+
+	if *directory == "" {
+		fmt.Fprint(os.Stderr, "must specify --directory")
+		return 1
+	}
+
+	loader := loaderFlags.newLoader()
+	expectations, err := loader.LoadExpectations(ctx, *directory)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load yaml files: %s", err.Error())
+		return 1
+	}
+
+	db, err := dbFlags.open(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to connect to spanner: %s", err.Error())
+		return 1
+	}
+	defer db.Close()
+
+	assertions, err := db.Assert(ctx, expectations)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to assert: %s", err.Error())
+		return 1
+	}
+
+	if !printAssertions(os.Stderr, assertions) {
+		return 1
+	}
+
+	return 0
+}
+
+// printAssertions prints the failed assertions and reports whether all the assertions passed.
+func printAssertions(w io.Writer, assertions []*spanner.TableAssertion) bool {
+	passed := true
+	for _, a := range assertions {
+		if !a.Failed() {
+			continue
+		}
+		passed = false
+
+		fmt.Fprintf(w, "%s:\n", a.Table)
+
+		if a.ExpectedCount != nil && *a.ExpectedCount != a.ActualCount {
+			fmt.Fprintf(w, "  expected %d rows, but got %d rows\n", *a.ExpectedCount, a.ActualCount)
+		}
+
+		for _, rd := range a.Missing {
+			fmt.Fprintf(w, "  - missing %s\n", rd.Key)
+			for _, cd := range rd.Columns {
+				fmt.Fprintf(w, "      %s: %s\n", cd.Column, cd.Expected)
+			}
+		}
+
+		for _, rd := range a.Changed {
+			fmt.Fprintf(w, "  ~ unmatched %s\n", rd.Key)
+			for _, cd := range rd.Columns {
+				fmt.Fprintf(w, "      %s: expected %s, but got %s\n", cd.Column, cd.Expected, cd.Actual)
+			}
+		}
+	}
+
+	return passed
+}
@@ -5,61 +5,141 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/kauche/splanter/internal/spanner"
 	"github.com/kauche/splanter/internal/yaml"
 )
 
 func Exec() {
-	os.Exit(exec(context.Background()))
+	os.Exit(exec(context.Background(), os.Args[1:]))
 }
 
-func exec(ctx context.Context) int {
-	project := flag.String("project", "", "GCP Project ID")
-	instance := flag.String("instance", "", "Spanner Instance Name")
-	database := flag.String("database", "", "Spanner Database Name")
-	directory := flag.String("directory", "", "Directory contains yaml files")
-
-	flag.Parse()
+func exec(ctx context.Context, args []string) int {
+	// The load command runs when no subcommand is given, for compatibility.
+	name := "load"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
 
-	if *project == "" {
-		fmt.Fprint(os.Stderr, "must specify --project")
+	switch name {
+	case "load":
+		return load(ctx, args)
+	case "unload":
+		return unload(ctx, args)
+	case "snapshot":
+		return snapshot(ctx, args)
+	case "restore":
+		return restore(ctx, args)
+	case "diff":
+		return diff(ctx, args)
+	case "assert", "verify":
+		return assert(ctx, args)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command: %s", name)
 		return 1
 	}
+}
 
-	if *instance == "" {
-		fmt.Fprint(os.Stderr, "must specify --instance")
-		return 1
+type dbFlags struct {
+	project                   *string
+	instance                  *string
+	database                  *string
+	credentialsFile           *string
+	impersonateServiceAccount *string
+	endpoint                  *string
+	emulatorHost              *string
+	databaseRole              *string
+	protoDescriptors          *string
+}
+
+func registerDBFlags(fs *flag.FlagSet) *dbFlags {
+	return &dbFlags{
+		project:                   fs.String("project", "", "GCP Project ID"),
+		instance:                  fs.String("instance", "", "Spanner Instance Name"),
+		database:                  fs.String("database", "", "Spanner Database Name"),
+		credentialsFile:           fs.String("credentials-file", "", "Path to the credentials JSON file (default: Application Default Credentials)"),
+		impersonateServiceAccount: fs.String("impersonate-service-account", "", "Service account email to impersonate"),
+		endpoint:                  fs.String("endpoint", "", "Spanner API endpoint"),
+		emulatorHost:              fs.String("emulator-host", "", "Spanner emulator host (e.g. localhost:9010)"),
+		databaseRole:              fs.String("database-role", "", "Spanner database role for fine-grained access control"),
+		protoDescriptors:          fs.String("proto-descriptors", "", "Path to the FileDescriptorSet file of PROTO and ENUM columns"),
 	}
+}
 
-	if *database == "" {
-		fmt.Fprint(os.Stderr, "must specify --database")
-		return 1
+func (f *dbFlags) validate() error {
+	if *f.project == "" {
+		return fmt.Errorf("must specify --project")
 	}
 
-	if *directory == "" {
-		fmt.Fprint(os.Stderr, "must specify --directory")
-		return 1
+	if *f.instance == "" {
+		return fmt.Errorf("must specify --instance")
 	}
 
-	loader := yaml.NewLoader()
-	tables, err := loader.Load(ctx, *directory)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "failed to load yaml files: %s", err.Error())
-		return 1
+	if *f.database == "" {
+		return fmt.Errorf("must specify --database")
 	}
 
-	db, err := spanner.NewDB(ctx, *project, *instance, *database)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "failed to connect to spanner: %s", err.Error())
-		return 1
+	// The emulator is connected without authentication and at its own host, so these would be silently ignored.
+	if *f.emulatorHost != "" {
+		switch {
+		case *f.credentialsFile != "":
+			return fmt.Errorf("cannot specify --credentials-file with --emulator-host")
+		case *f.impersonateServiceAccount != "":
+			return fmt.Errorf("cannot specify --impersonate-service-account with --emulator-host")
+		case *f.endpoint != "":
+			return fmt.Errorf("cannot specify --endpoint with --emulator-host")
+		}
 	}
-	defer db.Close()
 
-	if err := db.Save(ctx, tables); err != nil {
-		fmt.Fprintf(os.Stderr, "failed to load data to spanner tables: %s", err.Error())
-		return 1
+	return nil
+}
+
+func (f *dbFlags) open(ctx context.Context) (*spanner.DB, error) {
+	return spanner.NewDB(ctx, *f.project, *f.instance, *f.database, f.options()...)
+}
+
+// options returns the options of the database connection given by the flags.
+func (f *dbFlags) options() []spanner.Option {
+	var opts []spanner.Option
+	if *f.credentialsFile != "" {
+		opts = append(opts, spanner.WithCredentialsFile(*f.credentialsFile))
+	}
+	if *f.impersonateServiceAccount != "" {
+		opts = append(opts, spanner.WithImpersonateServiceAccount(*f.impersonateServiceAccount))
+	}
+	if *f.endpoint != "" {
+		opts = append(opts, spanner.WithEndpoint(*f.endpoint))
+	}
+	if *f.emulatorHost != "" {
+		opts = append(opts, spanner.WithEmulatorHost(*f.emulatorHost))
+	}
+	if *f.databaseRole != "" {
+		opts = append(opts, spanner.WithDatabaseRole(*f.databaseRole))
+	}
+	if *f.protoDescriptors != "" {
+		opts = append(opts, spanner.WithProtoDescriptorSet(*f.protoDescriptors))
+	}
+
+	return opts
+}
+
+type loaderFlags struct {
+	strict *bool
+}
+
+func registerLoaderFlags(fs *flag.FlagSet) *loaderFlags {
+	return &loaderFlags{
+		strict: fs.Bool("strict", false, "Reject duplicate keys, unknown tags and multiple documents in yaml files"),
+	}
+}
+
+// newLoader returns the loader of yaml files with the options given by the flags.
+func (f *loaderFlags) newLoader() *yaml.Loader {
+	var opts []yaml.Option
+	if *f.strict {
+		opts = append(opts, yaml.WithStrict())
 	}
 
-	return 0
+	return yaml.NewLoader(opts...)
 }
@@ -0,0 +1,84 @@
+package command
+
+import (
+	"flag"
+	"testing"
+)
+
+func parseDBFlags(t *testing.T, args []string) *dbFlags {
+	t.Helper()
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	f := registerDBFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("failed to parse flags: %s", err)
+	}
+
+	return f
+}
+
+func TestDBFlagsValidate(t *testing.T) {
+	t.Parallel()
+
+	required := []string{"--project", "p", "--instance", "i", "--database", "d"}
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{name: "required", args: required},
+		{name: "no project", args: []string{"--instance", "i", "--database", "d"}, expected: "must specify --project"},
+		{name: "no instance", args: []string{"--project", "p", "--database", "d"}, expected: "must specify --instance"},
+		{name: "no database", args: []string{"--project", "p", "--instance", "i"}, expected: "must specify --database"},
+		{name: "emulator host", args: append([]string{"--emulator-host", "localhost:9010", "--database-role", "r"}, required...)},
+		{name: "credentials file with emulator host", args: append([]string{"--emulator-host", "localhost:9010", "--credentials-file", "c.json"}, required...), expected: "cannot specify --credentials-file with --emulator-host"},
+		{name: "impersonate service account with emulator host", args: append([]string{"--emulator-host", "localhost:9010", "--impersonate-service-account", "sa@p.iam.gserviceaccount.com"}, required...), expected: "cannot specify --impersonate-service-account with --emulator-host"},
+		{name: "endpoint with emulator host", args: append([]string{"--emulator-host", "localhost:9010", "--endpoint", "spanner.example.com:443"}, required...), expected: "cannot specify --endpoint with --emulator-host"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := parseDBFlags(t, tt.args).validate()
+			switch {
+			case tt.expected == "" && err != nil:
+				t.Errorf("unexpected error: %s", err)
+			case tt.expected != "" && (err == nil || err.Error() != tt.expected):
+				t.Errorf("expected the error %q, but got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestDBFlagsOptions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected int
+	}{
+		{name: "none", args: nil, expected: 0},
+		{name: "credentials file", args: []string{"--credentials-file", "c.json"}, expected: 1},
+		{name: "impersonate service account", args: []string{"--impersonate-service-account", "sa@p.iam.gserviceaccount.com"}, expected: 1},
+		{name: "endpoint", args: []string{"--endpoint", "spanner.example.com:443"}, expected: 1},
+		{name: "emulator host", args: []string{"--emulator-host", "localhost:9010"}, expected: 1},
+		{name: "database role", args: []string{"--database-role", "r"}, expected: 1},
+		{name: "proto descriptors", args: []string{"--proto-descriptors", "descriptors.pb"}, expected: 1},
+		{name: "all", args: []string{"--credentials-file", "c.json", "--impersonate-service-account", "sa@p.iam.gserviceaccount.com", "--endpoint", "spanner.example.com:443", "--database-role", "r", "--proto-descriptors", "descriptors.pb"}, expected: 5},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if opts := parseDBFlags(t, tt.args).options(); len(opts) != tt.expected {
+				t.Errorf("expected %d options, but got %d", tt.expected, len(opts))
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package command
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kauche/splanter/internal/spanner"
+)
+
+func diff(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	dbFlags := registerDBFlags(fs)
+	directory := fs.String("directory", "", "Directory contains yaml files")
+	loaderFlags := registerLoaderFlags(fs)
+	extra := fs.Bool("extra", false, "Also report rows which exist only in the database")
+
+	fs.Parse(args)
+
+	if err := dbFlags.validate(); err != nil {
+		fmt.Fprint(os.Stderr, err.Error())
+		return 1
+	}
+
+	if *directory == "" {
+		fmt.Fprint(os.Stderr, "must specify --directory")
+		return 1
+	}
+
+	loader := loaderFlags.newLoader()
+	tables, err := loader.Load(ctx, *directory)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load yaml files: %s", err.Error())
+		return 1
+	}
+
+	db, err := dbFlags.open(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to connect to spanner: %s", err.Error())
+		return 1
+	}
+	defer db.Close()
+
+	diffs, err := db.Diff(ctx, tables, *extra)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to diff: %s", err.Error())
+		return 1
+	}
+
+	if !printDiffs(os.Stdout, diffs) {
+		return 1
+	}
+
+	return 0
+}
+
+// printDiffs prints the differences and the skipped records, and reports whether there is no difference.
+func printDiffs(w io.Writer, diffs []*spanner.TableDiff) bool {
+	same := true
+	for _, d := range diffs {
+		if d.Empty() && len(d.Skipped) == 0 {
+			continue
+		}
+		if !d.Empty() {
+			same = false
+		}
+
+		fmt.Fprintf(w, "%s:\n", d.Table)
+
+		for _, sr := range d.Skipped {
+			fmt.Fprintf(w, "  ? skipped %s: %s\n", sr.Position, sr.Reason)
+		}
+
+		for _, rd := range d.Missing {
+			fmt.Fprintf(w, "  - missing %s\n", rd.Key)
+			for _, cd := range rd.Columns {
+				fmt.Fprintf(w, "      %s: %s\n", cd.Column, cd.Expected)
+			}
+		}
+
+		for _, rd := range d.Extra {
+			fmt.Fprintf(w, "  + extra %s\n", rd.Key)
+			for _, cd := range rd.Columns {
+				fmt.Fprintf(w, "      %s: %s\n", cd.Column, cd.Actual)
+			}
+		}
+
+		for _, rd := range d.Changed {
+			fmt.Fprintf(w, "  ~ changed %s\n", rd.Key)
+			for _, cd := range rd.Columns {
+				fmt.Fprintf(w, "      %s: %s (fixture) != %s (database)\n", cd.Column, cd.Expected, cd.Actual)
+			}
+		}
+	}
+
+	return same
+}
@@ -0,0 +1,78 @@
+package command
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func load(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("load", flag.ExitOnError)
+	dbFlags := registerDBFlags(fs)
+	directory := fs.String("directory", "", "Directory contains yaml files")
+	loaderFlags := registerLoaderFlags(fs)
+	watchFiles := fs.Bool("watch", false, "Watch the directory and re-load changed yaml files")
+	reset := fs.Bool("reset", false, "Delete the rows loaded from changed yaml files before re-loading them in watch mode")
+
+	fs.Parse(args)
+
+	if err := dbFlags.validate(); err != nil {
+		fmt.Fprint(os.Stderr, err.Error())
+		return 1
+	}
+
+	if *directory == "" {
+		fmt.Fprint(os.Stderr, "must specify --directory")
+		return 1
+	}
+
+	// In watch mode, the files are loaded one by one after connecting, and the errors are printed and the watch continues
+	// so that they can be fixed without restarting.
+	if *watchFiles {
+		db, err := dbFlags.open(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to connect to spanner: %s", err.Error())
+			return 1
+		}
+		defer db.Close()
+
+		w := newWatcher(db, loaderFlags.newLoader(), *directory, *reset)
+		if err := w.seed(ctx); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+		}
+
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		if err := w.watch(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to watch yaml files: %s", err.Error())
+			return 1
+		}
+
+		return 0
+	}
+
+	// The yaml files are parsed before connecting, so that errors in them are reported without credentials.
+	tables, err := loaderFlags.newLoader().Load(ctx, *directory)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load yaml files: %s", err.Error())
+		return 1
+	}
+
+	db, err := dbFlags.open(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to connect to spanner: %s", err.Error())
+		return 1
+	}
+	defer db.Close()
+
+	if err := db.Save(ctx, tables); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load data to spanner tables: %s", err.Error())
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,107 @@
+package command
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kauche/splanter/internal/spanner"
+)
+
+func snapshot(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("snapshot", flag.ExitOnError)
+	dbFlags := registerDBFlags(fs)
+	tables := fs.String("tables", "", "Comma separated table names to capture")
+	file := fs.String("file", "", "Path to the snapshot file to write")
+
+	fs.Parse(args)
+
+	if err := dbFlags.validate(); err != nil {
+		fmt.Fprint(os.Stderr, err.Error())
+		return 1
+	}
+
+	if *tables == "" {
+		fmt.Fprint(os.Stderr, "must specify --tables")
+		return 1
+	}
+
+	if *file == "" {
+		fmt.Fprint(os.Stderr, "must specify --file")
+		return 1
+	}
+
+	db, err := dbFlags.open(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to connect to spanner: %s", err.Error())
+		return 1
+	}
+	defer db.Close()
+
+	s, err := db.Snapshot(ctx, strings.Split(*tables, ","))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to take snapshot: %s", err.Error())
+		return 1
+	}
+
+	f, err := os.Create(*file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create snapshot file: %s", err.Error())
+		return 1
+	}
+	defer f.Close()
+
+	if err := s.Write(f); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write snapshot file: %s", err.Error())
+		return 1
+	}
+
+	return 0
+}
+
+func restore(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	dbFlags := registerDBFlags(fs)
+	file := fs.String("file", "", "Path to the snapshot file to restore")
+
+	fs.Parse(args)
+
+	if err := dbFlags.validate(); err != nil {
+		fmt.Fprint(os.Stderr, err.Error())
+		return 1
+	}
+
+	if *file == "" {
+		fmt.Fprint(os.Stderr, "must specify --file")
+		return 1
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open snapshot file: %s", err.Error())
+		return 1
+	}
+	defer f.Close()
+
+	s, err := spanner.ReadSnapshot(f)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read snapshot file: %s", err.Error())
+		return 1
+	}
+
+	db, err := dbFlags.open(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to connect to spanner: %s", err.Error())
+		return 1
+	}
+	defer db.Close()
+
+	if err := db.Restore(ctx, s); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to restore snapshot: %s", err.Error())
+		return 1
+	}
+
+	return 0
+}
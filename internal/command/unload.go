@@ -0,0 +1,53 @@
+package command
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+)
+
+func unload(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("unload", flag.ExitOnError)
+	dbFlags := registerDBFlags(fs)
+	directory := fs.String("directory", "", "Directory contains yaml files")
+	loaderFlags := registerLoaderFlags(fs)
+
+	fs.Parse(args)
+
+	if err := dbFlags.validate(); err != nil {
+		fmt.Fprint(os.Stderr, err.Error())
+		return 1
+	}
+
+	if *directory == "" {
+		fmt.Fprint(os.Stderr, "must specify --directory")
+		return 1
+	}
+
+	loader := loaderFlags.newLoader()
+	tables, err := loader.Load(ctx, *directory)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load yaml files: %s", err.Error())
+		return 1
+	}
+
+	db, err := dbFlags.open(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to connect to spanner: %s", err.Error())
+		return 1
+	}
+	defer db.Close()
+
+	skipped, err := db.Delete(ctx, tables)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to delete data from spanner tables: %s", err.Error())
+		return 1
+	}
+
+	for _, sr := range skipped {
+		fmt.Fprintf(os.Stderr, "skipped %s: %s\n", sr.Position, sr.Reason)
+	}
+
+	return 0
+}
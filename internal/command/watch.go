@@ -0,0 +1,295 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/kauche/splanter/internal/model"
+	"github.com/kauche/splanter/internal/spanner"
+	"github.com/kauche/splanter/internal/yaml"
+)
+
+// watchDebounce is the time to wait for following events, since editors often write a file several times on save.
+const watchDebounce = 200 * time.Millisecond
+
+// store is the database which the yaml files are written to.
+type store interface {
+	Save(ctx context.Context, tables []*model.Table) error
+	Delete(ctx context.Context, tables []*model.Table) ([]*spanner.SkippedRecord, error)
+}
+
+// watcher re-loads the yaml files under directory whenever they change.
+type watcher struct {
+	db        store
+	loader    *yaml.Loader
+	directory string
+	// reset deletes the rows loaded from a changed file last time before re-loading the file.
+	reset bool
+
+	// loaded is the table loaded from each file last time, by the slash-separated path relative to directory.
+	loaded map[string]*model.Table
+}
+
+func newWatcher(db store, loader *yaml.Loader, directory string, reset bool) *watcher {
+	return &watcher{
+		db:        db,
+		loader:    loader,
+		directory: directory,
+		reset:     reset,
+		loaded:    make(map[string]*model.Table),
+	}
+}
+
+// save writes the tables loaded from the directory, and remembers them to reset the rows of each file later.
+func (w *watcher) save(ctx context.Context, tables []*model.Table) error {
+	if err := w.db.Save(ctx, tables); err != nil {
+		return fmt.Errorf("failed to load data to spanner tables: %w", err)
+	}
+
+	for _, t := range tables {
+		w.loaded[t.File] = t
+	}
+
+	return nil
+}
+
+// seed loads the table files under directory one by one and saves the loaded ones, so that a file failing to load
+// does not block the others. The errors of the files are returned after saving, and the files are loaded again when they change.
+func (w *watcher) seed(ctx context.Context) error {
+	var (
+		tables []*model.Table
+		errs   []error
+	)
+	err := filepath.WalkDir(w.directory, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(w.directory, p)
+		if err != nil {
+			return err
+		}
+
+		file := filepath.ToSlash(rel)
+		if !isTableFile(file) {
+			return nil
+		}
+
+		table, err := w.loader.LoadFile(ctx, w.directory, file)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to load yaml file %s: %w", file, err))
+			return nil
+		}
+		tables = append(tables, table)
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to walk %s: %w", w.directory, err)
+	}
+
+	if len(tables) > 0 {
+		if err := w.save(ctx, tables); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// watch re-loads the changed yaml files until ctx is done. Errors are printed and the watch continues.
+func (w *watcher) watch(ctx context.Context) error {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create watcher: %w", err)
+	}
+	defer fw.Close()
+
+	if err := addWatchDirs(fw, w.directory); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "watching %s\n", w.directory)
+
+	changed := make(map[string]bool)
+	timer := time.NewTimer(watchDebounce)
+	timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-fw.Errors:
+			fmt.Fprintf(os.Stderr, "failed to watch files: %s\n", err.Error())
+		case event := <-fw.Events:
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := addWatchDirs(fw, event.Name); err != nil {
+						fmt.Fprintln(os.Stderr, err.Error())
+					}
+					continue
+				}
+			}
+
+			if event.Op&(fsnotify.Create|fsnotify.Write|fsnotify.Rename) == 0 {
+				continue
+			}
+
+			rel, err := filepath.Rel(w.directory, event.Name)
+			if err != nil {
+				continue
+			}
+
+			files := w.targets(filepath.ToSlash(rel))
+			if len(files) == 0 {
+				continue
+			}
+
+			for _, file := range files {
+				changed[file] = true
+			}
+			timer.Reset(watchDebounce)
+		case <-timer.C:
+			files := make([]string, 0, len(changed))
+			for file := range changed {
+				files = append(files, file)
+			}
+			sort.Strings(files)
+			changed = make(map[string]bool)
+
+			if err := w.reload(ctx, files); err != nil {
+				fmt.Fprintln(os.Stderr, err.Error())
+				continue
+			}
+
+			fmt.Fprintf(os.Stderr, "reloaded %v\n", files)
+		}
+	}
+}
+
+func addWatchDirs(watcher *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !entry.IsDir() {
+			return nil
+		}
+
+		if err := watcher.Add(path); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+
+		return nil
+	})
+}
+
+// targets returns the yaml files to reload when the file at the slash-separated path relative to directory changes,
+// which are the file itself if it is loaded as a table, and the files which include it.
+// Files under the paths starting with `_` are not loaded as tables, the same as Load.
+// All the files are reloaded when the config file changes, since it applies to all the tables.
+func (w *watcher) targets(file string) []string {
+	var files []string
+	if file == yaml.ConfigFile {
+		for f := range w.loaded {
+			files = append(files, f)
+		}
+		sort.Strings(files)
+
+		return files
+	}
+
+	if isTableFile(file) {
+		files = append(files, file)
+	}
+
+	for f, t := range w.loaded {
+		if f == file {
+			continue
+		}
+
+		for _, include := range t.Includes {
+			if include == file {
+				files = append(files, f)
+				break
+			}
+		}
+	}
+	sort.Strings(files)
+
+	return files
+}
+
+// isTableFile reports whether the file at the slash-separated path is loaded as a table.
+func isTableFile(file string) bool {
+	ext := path.Ext(file)
+	if ext != ".yaml" && ext != ".yml" {
+		return false
+	}
+
+	for _, name := range strings.Split(file, "/") {
+		if strings.HasPrefix(name, "_") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// reload loads the changed files. Files which no longer exist (e.g. renamed by editors) are skipped.
+// With reset, the rows loaded from the files last time are deleted first, so that rows removed from the files are removed
+// from the database too, while rows loaded from the other files of the same tables are left as they are.
+func (w *watcher) reload(ctx context.Context, files []string) error {
+	var tables []*model.Table
+	for _, file := range files {
+		if _, err := os.Stat(filepath.Join(w.directory, filepath.FromSlash(file))); err != nil {
+			continue
+		}
+
+		table, err := w.loader.LoadFile(ctx, w.directory, file)
+		if err != nil {
+			return fmt.Errorf("failed to load yaml file %s: %w", file, err)
+		}
+		tables = append(tables, table)
+	}
+
+	if len(tables) == 0 {
+		return nil
+	}
+
+	if w.reset {
+		var previous []*model.Table
+		for _, table := range tables {
+			if t, ok := w.loaded[table.File]; ok && len(t.Records) > 0 {
+				previous = append(previous, t)
+			}
+		}
+
+		if len(previous) > 0 {
+			// The keys of the previous records are resolved by the save, so none of them are skipped.
+			if _, err := w.db.Delete(ctx, previous); err != nil {
+				return fmt.Errorf("failed to reset the rows of the changed files: %w", err)
+			}
+		}
+
+		for _, table := range tables {
+			delete(w.loaded, table.File)
+		}
+	}
+
+	return w.save(ctx, tables)
+}
@@ -0,0 +1,259 @@
+package command
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/kauche/splanter/internal/model"
+	"github.com/kauche/splanter/internal/spanner"
+	"github.com/kauche/splanter/internal/yaml"
+)
+
+type fakeStore struct {
+	mu      sync.Mutex
+	saved   [][]*model.Table
+	deleted [][]*model.Table
+	// savedCh receives a value on each Save.
+	savedCh chan struct{}
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{savedCh: make(chan struct{}, 10)}
+}
+
+func (s *fakeStore) Save(ctx context.Context, tables []*model.Table) error {
+	s.mu.Lock()
+	s.saved = append(s.saved, tables)
+	s.mu.Unlock()
+
+	s.savedCh <- struct{}{}
+	return nil
+}
+
+func (s *fakeStore) Delete(ctx context.Context, tables []*model.Table) ([]*spanner.SkippedRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deleted = append(s.deleted, tables)
+	return nil, nil
+}
+
+// recordIDs returns the IDs of the records of the tables by the table name and the file.
+func recordIDs(tables []*model.Table) map[string][]interface{} {
+	ids := make(map[string][]interface{})
+	for _, t := range tables {
+		for _, r := range t.Records {
+			ids[t.File] = append(ids[t.File], r.Values["ID"])
+		}
+	}
+	return ids
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("failed to create the directory: %s", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write %s: %s", path, err)
+	}
+}
+
+func TestWatcherReload(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	for _, reset := range []bool{false, true} {
+		reset := reset
+		t.Run(map[bool]string{false: "keep", true: "reset"}[reset], func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			writeFile(t, filepath.Join(dir, "a", "Foo.yaml"), "- ID: 1\n- ID: 2\n")
+			writeFile(t, filepath.Join(dir, "b", "Foo.yaml"), "- ID: 3\n")
+
+			loader := yaml.NewLoader()
+			tables, err := loader.Load(ctx, dir)
+			if err != nil {
+				t.Fatalf("failed to load: %s", err)
+			}
+
+			db := newFakeStore()
+			w := newWatcher(db, loader, dir, reset)
+			if err := w.save(ctx, tables); err != nil {
+				t.Fatalf("failed to save: %s", err)
+			}
+
+			writeFile(t, filepath.Join(dir, "a", "Foo.yaml"), "- ID: 1\n")
+			if err := w.reload(ctx, []string{"a/Foo.yaml"}); err != nil {
+				t.Fatalf("failed to reload: %s", err)
+			}
+
+			if diff := cmp.Diff(recordIDs(db.saved[1]), map[string][]interface{}{"a/Foo.yaml": {int64(1)}}); diff != "" {
+				t.Errorf("\n(-actual, +expected)\n%s", diff)
+			}
+
+			// Only the rows loaded from the changed file are deleted, not the rows of b/Foo.yaml.
+			var expected [][]*model.Table
+			if reset {
+				expected = [][]*model.Table{{tables[0]}}
+			}
+			if diff := cmp.Diff(db.deleted, expected); diff != "" {
+				t.Errorf("\n(-actual, +expected)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWatcherSeed(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a", "Foo.yaml"), "- ID: 1\n")
+	writeFile(t, filepath.Join(dir, "b", "Foo.yaml"), "ID: 2\n")
+	writeFile(t, filepath.Join(dir, "_shared", "Bar.yaml"), "- ID: 2\n")
+
+	db := newFakeStore()
+	w := newWatcher(db, yaml.NewLoader(), dir, false)
+
+	// The broken file is reported, and the other files are still saved.
+	err := w.seed(ctx)
+	if err == nil || !strings.Contains(err.Error(), "failed to load yaml file b/Foo.yaml") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if len(db.saved) != 1 {
+		t.Fatalf("expected a save, but got %d", len(db.saved))
+	}
+	if diff := cmp.Diff(recordIDs(db.saved[0]), map[string][]interface{}{"a/Foo.yaml": {int64(1)}}); diff != "" {
+		t.Errorf("\n(-actual, +expected)\n%s", diff)
+	}
+
+	// The broken file is loaded when it is fixed.
+	if diff := cmp.Diff(w.targets("b/Foo.yaml"), []string{"b/Foo.yaml"}); diff != "" {
+		t.Errorf("\n(-actual, +expected)\n%s", diff)
+	}
+}
+
+func TestWatcherTargets(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "_splanter.yaml"), "duplicate_keys: error\n")
+	writeFile(t, filepath.Join(dir, "_fragments", "rows.yaml"), "- ID: 1\n")
+	writeFile(t, filepath.Join(dir, "_fragments", "columns.yaml"), "Name: foo\n")
+	writeFile(t, filepath.Join(dir, "_fragments", "unused.yaml"), "- ID: 9\n")
+	// The paths of the includes are resolved against the directory, the same as Load.
+	writeFile(t, filepath.Join(dir, "a", "Foo.yaml"), "- !include ../_fragments/rows.yaml\n")
+	writeFile(t, filepath.Join(dir, "b", "Foo.yaml"), "- ID: 2\n  <<: !include /_fragments/columns.yaml\n")
+
+	loader := yaml.NewLoader()
+	tables, err := loader.Load(ctx, dir)
+	if err != nil {
+		t.Fatalf("failed to load: %s", err)
+	}
+
+	db := newFakeStore()
+	w := newWatcher(db, loader, dir, false)
+	if err := w.save(ctx, tables); err != nil {
+		t.Fatalf("failed to save: %s", err)
+	}
+
+	tests := []struct {
+		file     string
+		expected []string
+	}{
+		{file: "a/Foo.yaml", expected: []string{"a/Foo.yaml"}},
+		{file: "c/Bar.yml", expected: []string{"c/Bar.yml"}},
+		{file: "_fragments/rows.yaml", expected: []string{"a/Foo.yaml"}},
+		{file: "_fragments/columns.yaml", expected: []string{"b/Foo.yaml"}},
+		{file: "_fragments/unused.yaml"},
+		{file: "_splanter.yaml", expected: []string{"a/Foo.yaml", "b/Foo.yaml"}},
+		{file: "a/_Foo.yaml"},
+		{file: "a/Foo.txt"},
+	}
+
+	for _, tt := range tests {
+		if diff := cmp.Diff(w.targets(tt.file), tt.expected); diff != "" {
+			t.Errorf("%s: \n(-actual, +expected)\n%s", tt.file, diff)
+		}
+	}
+
+	writeFile(t, filepath.Join(dir, "_fragments", "rows.yaml"), "- ID: 3\n")
+	if err := w.reload(ctx, w.targets("_fragments/rows.yaml")); err != nil {
+		t.Fatalf("failed to reload: %s", err)
+	}
+
+	if diff := cmp.Diff(recordIDs(db.saved[1]), map[string][]interface{}{"a/Foo.yaml": {int64(3)}}); diff != "" {
+		t.Errorf("\n(-actual, +expected)\n%s", diff)
+	}
+
+	// The config file is reread on reload rather than kept from the first load.
+	writeFile(t, filepath.Join(dir, "_splanter.yaml"), "duplicate_keys: override\n")
+	if err := w.reload(ctx, w.targets("_splanter.yaml")); err != nil {
+		t.Fatalf("failed to reload: %s", err)
+	}
+
+	for _, table := range db.saved[2] {
+		if !table.OverrideDuplicates {
+			t.Errorf("expected %s to be reloaded with the new config", table.File)
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dir := t.TempDir()
+	db := newFakeStore()
+	w := newWatcher(db, yaml.NewLoader(), dir, false)
+
+	done := make(chan error)
+	go func() {
+		done <- w.watch(ctx)
+	}()
+
+	// The file is written until it is reloaded, since the watch may not have started yet.
+	path := filepath.Join(dir, "Foo.yaml")
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	timeout := time.After(10 * time.Second)
+
+	writeFile(t, path, "- ID: 1\n")
+loop:
+	for {
+		select {
+		case <-db.savedCh:
+			break loop
+		case <-ticker.C:
+			writeFile(t, path, "- ID: 1\n")
+		case <-timeout:
+			t.Fatal("the changed file is not reloaded")
+		}
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("failed to watch: %s", err)
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if diff := cmp.Diff(recordIDs(db.saved[0]), map[string][]interface{}{"Foo.yaml": {int64(1)}}); diff != "" {
+		t.Errorf("\n(-actual, +expected)\n%s", diff)
+	}
+}
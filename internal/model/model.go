@@ -1,10 +1,90 @@
 package model
 
+import (
+	"regexp"
+	"time"
+)
+
 type Table struct {
 	Name    string
 	Records []*Record
+
+	// File is the path of the yaml file of the table, relative to the loaded directory.
+	File string
+	// Includes is the paths of the files read by the tags such as `!include` and `!file` in the yaml file,
+	// relative to the loaded directory.
+	Includes []string
+
+	// Rules fills the columns omitted in the records, which are applied when the records are saved.
+	Rules []*ColumnRule
+
+	// Source is the content of the yaml file of the table, which is used to print the positions of the records in errors.
+	Source []byte
+
+	// OverrideDuplicates makes a record override the columns of the record of the same primary key read before,
+	// instead of failing the save.
+	OverrideDuplicates bool
+}
+
+// ColumnRule fills the columns whose names match Column with Value, in the tables whose names match Table.
+// Column and Table are patterns of path.Match, and empty Table means all the tables.
+type ColumnRule struct {
+	Table  string
+	Column string
+	Value  interface{}
+
+	// Volatile is true if Value differs on every load such as `!now`, so the filled columns are not compared by Diff.
+	Volatile bool
 }
 
 type Record struct {
 	Values map[string]interface{}
+
+	// Name is the name to refer to the record from other records with Reference. Empty means the record has no name.
+	Name string
+
+	// Position is where the record is written, which is used in error messages.
+	Position Position
+	// Positions is where the value of each column is written. Columns not in it are located at Position.
+	Positions map[string]Position
+}
+
+// Reference is a value which refers to a column of the named record, resolved when the records are saved.
+// It is mainly used to refer to the keys generated by sequences.
+type Reference struct {
+	Name   string
+	Column string
+}
+
+// File is the content of a file referred by a value, which is written as the bytes of BYTES columns,
+// the text of STRING columns or the document of JSON columns.
+type File struct {
+	Path    string
+	Content []byte
+}
+
+// Expectation is the expected contents of a table.
+// Values of the records may be matchers such as AnyMatcher instead of concrete values.
+type Expectation struct {
+	Name    string
+	Records []*Record
+
+	// Count is the expected number of rows in the table. nil means any number.
+	Count *int64
+	// Ignore is the columns which are not compared.
+	Ignore []string
+}
+
+// AnyMatcher matches any value including NULL.
+type AnyMatcher struct{}
+
+// RegexpMatcher matches values whose string representation matches the regular expression.
+type RegexpMatcher struct {
+	Regexp *regexp.Regexp
+}
+
+// WithinMatcher matches TIMESTAMP values within Duration of Time. Zero Time means the time of the matching.
+type WithinMatcher struct {
+	Time     time.Time
+	Duration time.Duration
 }
@@ -0,0 +1,83 @@
+package model
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// snippetLines is the number of lines printed before and after the line of the position in snippets.
+const snippetLines = 3
+
+// Position is a location in a yaml file. Line and Column start with 1, and 0 means unknown.
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	switch {
+	case p.Line == 0:
+		return p.File
+	case p.Column == 0:
+		return fmt.Sprintf("%s:%d", p.File, p.Line)
+	default:
+		return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+	}
+}
+
+// Snippet returns the lines of the source around the position, marking the line and the column of the position.
+//
+//	   1 | ---
+//	>  2 | - ID: 1
+//	         ^
+//	   3 |   Name: foo
+func (p Position) Snippet(source []byte) string {
+	if p.Line == 0 || len(source) == 0 {
+		return ""
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(source))
+	scanner.Buffer(nil, len(source)+1)
+	for n := 1; scanner.Scan() && n <= p.Line+snippetLines; n++ {
+		if n < p.Line-snippetLines {
+			continue
+		}
+
+		prefix := fmt.Sprintf("  %2d | ", n)
+		if n == p.Line {
+			prefix = fmt.Sprintf("> %2d | ", n)
+		}
+		lines = append(lines, prefix+scanner.Text())
+
+		if n == p.Line && p.Column > 0 {
+			lines = append(lines, strings.Repeat(" ", len(prefix)+p.Column-1)+"^")
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// PositionError is an error at a position in a yaml file, which is printed with the snippet of the source.
+type PositionError struct {
+	Position Position
+	// Source is the content of the file, which may be nil.
+	Source []byte
+	Err    error
+}
+
+func (e *PositionError) Error() string {
+	msg := fmt.Sprintf("%s: %s", e.Position, e.Err)
+	if snippet := e.Position.Snippet(e.Source); snippet != "" {
+		msg += "\n" + snippet
+	}
+
+	return msg
+}
+
+func (e *PositionError) Unwrap() error {
+	return e.Err
+}
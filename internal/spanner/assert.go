@@ -0,0 +1,268 @@
+package spanner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/spanner"
+
+	"github.com/kauche/splanter/internal/model"
+)
+
+// TableAssertion is the result of asserting the rows of a table against an expectation.
+type TableAssertion struct {
+	Table string
+
+	// ExpectedCount is the expected number of rows, or nil if the number is not expected.
+	ExpectedCount *int64
+	ActualCount   int64
+
+	// Missing is the expected rows which no row in the database matches.
+	Missing []*RowDiff
+	// Changed is the expected rows whose key exists in the database but whose values do not match.
+	Changed []*RowDiff
+}
+
+func (a *TableAssertion) Failed() bool {
+	return (a.ExpectedCount != nil && *a.ExpectedCount != a.ActualCount) || len(a.Missing) > 0 || len(a.Changed) > 0
+}
+
+// Assert checks the rows in the database against the expectations.
+// Expected rows having the whole primary key are compared with the row of the key,
+// and the other rows are compared with every row of the table to find a matching one.
+// Only the specified columns except for the ignored ones are compared. The rules of the seed files are not applied to the expectations.
+func (d *DB) Assert(ctx context.Context, expectations []*model.Expectation) ([]*TableAssertion, error) {
+	tableNames := make([]string, len(expectations))
+	for i, e := range expectations {
+		tableNames[i] = e.Name
+	}
+
+	schemas, err := d.selectTableSchemas(ctx, tableNames)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get table schemas: %w", err)
+	}
+
+	tx := d.client.ReadOnlyTransaction()
+	defer tx.Close()
+
+	now := time.Now()
+
+	assertions := make([]*TableAssertion, len(expectations))
+	for i, e := range expectations {
+		ts := schemas[e.Name]
+
+		ignore := make(map[string]bool, len(e.Ignore))
+		for _, c := range e.Ignore {
+			if _, ok := ts.columnMap[c]; !ok {
+				return nil, fmt.Errorf("ignored column %s is not found in %s", c, e.Name)
+			}
+			ignore[c] = true
+		}
+
+		var rows [][]interface{}
+		rowIndexes := make(map[string]int)
+		err := tx.Read(ctx, e.Name, spanner.AllKeys(), ts.columnNames()).Do(func(row *spanner.Row) error {
+			values, err := ts.readRow(row)
+			if err != nil {
+				return err
+			}
+
+			rowIndexes[formatKey(ts.rowKey(values))] = len(rows)
+			rows = append(rows, values)
+
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", e.Name, err)
+		}
+
+		assertion := &TableAssertion{
+			Table:         e.Name,
+			ExpectedCount: e.Count,
+			ActualCount:   int64(len(rows)),
+		}
+
+		matched := make([]bool, len(rows))
+		for _, record := range e.Records {
+			for column := range record.Values {
+				if _, ok := ts.columnMap[column]; !ok {
+					return nil, fmt.Errorf("column %s is not found in %s", column, e.Name)
+				}
+			}
+
+			if key, ok := expectedKey(ts, record); ok {
+				rd := &RowDiff{Key: formatKey(key)}
+
+				idx, exists := rowIndexes[rd.Key]
+				if !exists {
+					rd.Columns, err = matchRow(ts, record, ignore, nil, now)
+					if err != nil {
+						return nil, err
+					}
+					assertion.Missing = append(assertion.Missing, rd)
+					continue
+				}
+
+				matched[idx] = true
+				rd.Columns, err = matchRow(ts, record, ignore, rows[idx], now)
+				if err != nil {
+					return nil, err
+				}
+				if len(rd.Columns) > 0 {
+					assertion.Changed = append(assertion.Changed, rd)
+				}
+
+				continue
+			}
+
+			found := false
+			for j, row := range rows {
+				if matched[j] {
+					continue
+				}
+
+				diffs, err := matchRow(ts, record, ignore, row, now)
+				if err != nil {
+					return nil, err
+				}
+				if len(diffs) == 0 {
+					matched[j] = true
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				rd := &RowDiff{Key: "(no matching row)"}
+				rd.Columns, err = matchRow(ts, record, ignore, nil, now)
+				if err != nil {
+					return nil, err
+				}
+				assertion.Missing = append(assertion.Missing, rd)
+			}
+		}
+
+		assertions[i] = assertion
+	}
+
+	return assertions, nil
+}
+
+// expectedKey returns the primary key of the expected record if every key column has a concrete value.
+func expectedKey(ts *tableSchema, record *model.Record) (spanner.Key, bool) {
+	for _, c := range ts.primaryKey {
+		v, ok := record.Values[c.name]
+		if !ok || isMatcher(v) {
+			return nil, false
+		}
+	}
+
+	key, err := ts.recordKey(record)
+	if err != nil {
+		return nil, false
+	}
+
+	return key, true
+}
+
+func isMatcher(v interface{}) bool {
+	switch v.(type) {
+	case model.AnyMatcher, model.RegexpMatcher, model.WithinMatcher:
+		return true
+	default:
+		return false
+	}
+}
+
+// matchRow returns the columns of the record which do not match the row.
+// When row is nil, all the compared columns are returned with the expected values.
+func matchRow(ts *tableSchema, record *model.Record, ignore map[string]bool, row []interface{}, now time.Time) ([]*ColumnDiff, error) {
+	var diffs []*ColumnDiff
+	for i, c := range ts.columns {
+		expected, ok := record.Values[c.name]
+		if !ok || ignore[c.name] {
+			continue
+		}
+
+		if row == nil {
+			s, err := formatExpected(c, expected)
+			if err != nil {
+				return nil, err
+			}
+			diffs = append(diffs, &ColumnDiff{Column: c.name, Expected: s})
+			continue
+		}
+
+		ok, s, err := matchValue(c, expected, row[i], now)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			diffs = append(diffs, &ColumnDiff{Column: c.name, Expected: s, Actual: formatValue(row[i])})
+		}
+	}
+
+	return diffs, nil
+}
+
+func formatExpected(c *columnSchema, expected interface{}) (string, error) {
+	switch m := expected.(type) {
+	case model.AnyMatcher:
+		return "any", nil
+	case model.RegexpMatcher:
+		return fmt.Sprintf("/%s/", m.Regexp), nil
+	case model.WithinMatcher:
+		if m.Time.IsZero() {
+			return fmt.Sprintf("within %s of now", m.Duration), nil
+		}
+		return fmt.Sprintf("within %s of %s", m.Duration, m.Time.Format(time.RFC3339Nano)), nil
+	}
+
+	coerced, err := coerceValue(c.typ, expected)
+	if err != nil {
+		return "", fmt.Errorf("invalid value of %s: %w", c.name, err)
+	}
+
+	return formatValue(coerced), nil
+}
+
+// matchValue reports whether the actual value read from the database matches the expected value or matcher.
+func matchValue(c *columnSchema, expected, actual interface{}, now time.Time) (bool, string, error) {
+	s, err := formatExpected(c, expected)
+	if err != nil {
+		return false, "", err
+	}
+
+	switch m := expected.(type) {
+	case model.AnyMatcher:
+		return true, s, nil
+	case model.RegexpMatcher:
+		target := formatValue(actual)
+		if ns, ok := actual.(spanner.NullString); ok && ns.Valid {
+			target = ns.StringVal
+		}
+		return m.Regexp.MatchString(target), s, nil
+	case model.WithinMatcher:
+		t, ok := actual.(spanner.NullTime)
+		if !ok {
+			return false, "", fmt.Errorf("!within can be used only for TIMESTAMP columns but %s is %s", c.name, c.typ)
+		}
+		if !t.Valid {
+			return false, s, nil
+		}
+
+		base := m.Time
+		if base.IsZero() {
+			base = now
+		}
+
+		delta := t.Time.Sub(base)
+		if delta < 0 {
+			delta = -delta
+		}
+		return delta <= m.Duration, s, nil
+	}
+
+	return s == formatValue(actual), s, nil
+}
@@ -0,0 +1,172 @@
+package spanner
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/kauche/splanter/internal/model"
+)
+
+func assertTestSchema(t *testing.T) *tableSchema {
+	t.Helper()
+
+	ts := &tableSchema{name: "Users", columnMap: make(map[string]*columnSchema)}
+	for _, c := range []struct{ name, typ string }{
+		{"TenantID", "STRING(36)"},
+		{"UserID", "INT64"},
+		{"Name", "STRING(MAX)"},
+		{"CreatedAt", "TIMESTAMP"},
+	} {
+		typ, err := parseColumnType(c.typ)
+		if err != nil {
+			t.Fatalf("failed to parse type: %s", err)
+		}
+
+		cs := &columnSchema{name: c.name, typ: typ, nullable: true}
+		ts.columns = append(ts.columns, cs)
+		ts.columnMap[cs.name] = cs
+	}
+	ts.primaryKey = ts.columns[:2]
+
+	return ts
+}
+
+func TestExpectedKey(t *testing.T) {
+	t.Parallel()
+
+	ts := assertTestSchema(t)
+
+	tests := []struct {
+		name     string
+		values   map[string]interface{}
+		expected string
+	}{
+		{name: "whole key", values: map[string]interface{}{"TenantID": "t1", "UserID": uint64(1), "Name": model.AnyMatcher{}}, expected: `("t1", 1)`},
+		{name: "partial key", values: map[string]interface{}{"TenantID": "t1"}},
+		{name: "matcher in key", values: map[string]interface{}{"TenantID": "t1", "UserID": model.AnyMatcher{}}},
+		{name: "invalid key", values: map[string]interface{}{"TenantID": "t1", "UserID": "one"}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			key, ok := expectedKey(ts, &model.Record{Values: tt.values})
+			if ok != (tt.expected != "") {
+				t.Fatalf("expected the key %q, but got %v", tt.expected, key)
+			}
+
+			if ok && formatKey(key) != tt.expected {
+				t.Errorf("expected %s, but got %s", tt.expected, formatKey(key))
+			}
+		})
+	}
+}
+
+func TestMatchValue(t *testing.T) {
+	t.Parallel()
+
+	ts := assertTestSchema(t)
+	now := time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		column   string
+		expected interface{}
+		actual   interface{}
+		matched  bool
+		err      bool
+	}{
+		{name: "equal", column: "Name", expected: "foo", actual: spanner.NullString{StringVal: "foo", Valid: true}, matched: true},
+		{name: "not equal", column: "Name", expected: "foo", actual: spanner.NullString{StringVal: "bar", Valid: true}},
+		{name: "coerced", column: "UserID", expected: uint64(1), actual: spanner.NullInt64{Int64: 1, Valid: true}, matched: true},
+		{name: "null", column: "Name", expected: nil, actual: spanner.NullString{}, matched: true},
+		{name: "any", column: "Name", expected: model.AnyMatcher{}, actual: spanner.NullString{}, matched: true},
+		{name: "regex", column: "Name", expected: model.RegexpMatcher{Regexp: regexp.MustCompile(`^user-[0-9]+$`)}, actual: spanner.NullString{StringVal: "user-1", Valid: true}, matched: true},
+		{name: "regex not matched", column: "Name", expected: model.RegexpMatcher{Regexp: regexp.MustCompile(`^user-[0-9]+$`)}, actual: spanner.NullString{StringVal: "admin", Valid: true}},
+		{name: "regex of number", column: "UserID", expected: model.RegexpMatcher{Regexp: regexp.MustCompile(`^[0-9]{3}$`)}, actual: spanner.NullInt64{Int64: 123, Valid: true}, matched: true},
+		{name: "within now", column: "CreatedAt", expected: model.WithinMatcher{Duration: time.Minute}, actual: spanner.NullTime{Time: now.Add(-30 * time.Second), Valid: true}, matched: true},
+		{name: "not within now", column: "CreatedAt", expected: model.WithinMatcher{Duration: time.Minute}, actual: spanner.NullTime{Time: now.Add(2 * time.Minute), Valid: true}},
+		{name: "within time", column: "CreatedAt", expected: model.WithinMatcher{Time: now.Add(time.Hour), Duration: time.Hour}, actual: spanner.NullTime{Time: now, Valid: true}, matched: true},
+		{name: "within null", column: "CreatedAt", expected: model.WithinMatcher{Duration: time.Minute}, actual: spanner.NullTime{}},
+		{name: "within non-timestamp", column: "Name", expected: model.WithinMatcher{Duration: time.Minute}, actual: spanner.NullString{}, err: true},
+		{name: "invalid expected", column: "UserID", expected: "one", actual: spanner.NullInt64{Int64: 1, Valid: true}, err: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			matched, _, err := matchValue(ts.columnMap[tt.column], tt.expected, tt.actual, now)
+			if (err != nil) != tt.err {
+				t.Fatalf("expected error: %t, but got %v", tt.err, err)
+			}
+
+			if matched != tt.matched {
+				t.Errorf("expected matched: %t, but got %t", tt.matched, matched)
+			}
+		})
+	}
+}
+
+func TestMatchRow(t *testing.T) {
+	t.Parallel()
+
+	ts := assertTestSchema(t)
+	now := time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	row := []interface{}{
+		spanner.NullString{StringVal: "t1", Valid: true},
+		spanner.NullInt64{Int64: 1, Valid: true},
+		spanner.NullString{StringVal: "foo", Valid: true},
+		spanner.NullTime{Time: now, Valid: true},
+	}
+
+	record := &model.Record{Values: map[string]interface{}{
+		"TenantID":  "t1",
+		"Name":      "bar",
+		"CreatedAt": "2000-01-01T00:00:00Z",
+	}}
+
+	// UserID is not compared since the record omits it.
+	actual, err := matchRow(ts, record, nil, row, now)
+	if err != nil {
+		t.Fatalf("failed to match: %s", err)
+	}
+
+	expected := []*ColumnDiff{
+		{Column: "Name", Expected: `"bar"`, Actual: `"foo"`},
+		{Column: "CreatedAt", Expected: "2000-01-01T00:00:00Z", Actual: "2022-04-01T00:00:00Z"},
+	}
+	if diff := cmp.Diff(actual, expected); diff != "" {
+		t.Errorf("\n(-actual, +expected)\n%s", diff)
+	}
+
+	actual, err = matchRow(ts, record, map[string]bool{"Name": true, "CreatedAt": true}, row, now)
+	if err != nil {
+		t.Fatalf("failed to match: %s", err)
+	}
+	if len(actual) != 0 {
+		t.Errorf("expected no differences of the ignored columns, but got %v", actual)
+	}
+
+	// Without the row, all the compared columns are returned with the expected values.
+	actual, err = matchRow(ts, &model.Record{Values: map[string]interface{}{"TenantID": "t1", "Name": model.AnyMatcher{}}}, nil, nil, now)
+	if err != nil {
+		t.Fatalf("failed to match: %s", err)
+	}
+
+	expected = []*ColumnDiff{
+		{Column: "TenantID", Expected: `"t1"`},
+		{Column: "Name", Expected: "any"},
+	}
+	if diff := cmp.Diff(actual, expected); diff != "" {
+		t.Errorf("\n(-actual, +expected)\n%s", diff)
+	}
+}
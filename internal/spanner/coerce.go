@@ -0,0 +1,498 @@
+package spanner
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
+	spannerpb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/kauche/splanter/internal/model"
+)
+
+// nullValue returns the NULL value of the Go type which coerceValue returns for the column type.
+func nullValue(typ *columnType) interface{} {
+	switch typ.code {
+	case spannerpb.TypeCode_BOOL:
+		return spanner.NullBool{}
+	case spannerpb.TypeCode_INT64:
+		return spanner.NullInt64{}
+	case spannerpb.TypeCode_FLOAT64:
+		return spanner.NullFloat64{}
+	case spannerpb.TypeCode_TIMESTAMP:
+		return spanner.NullTime{}
+	case spannerpb.TypeCode_DATE:
+		return spanner.NullDate{}
+	case spannerpb.TypeCode_STRING:
+		return spanner.NullString{}
+	case spannerpb.TypeCode_BYTES:
+		return []byte(nil)
+	case spannerpb.TypeCode_NUMERIC:
+		return spanner.NullNumeric{}
+	case spannerpb.TypeCode_JSON:
+		return spanner.NullJSON{}
+	case typeCodeProto:
+		return []byte(nil)
+	case typeCodeEnum:
+		return spanner.NullInt64{}
+	case typeCodeFloat32:
+		return spanner.NullFloat64{}
+	case typeCodeInterval:
+		return spanner.NullString{}
+	case spannerpb.TypeCode_ARRAY:
+		elem := nullValue(typ.elem)
+		if elem == nil {
+			return nil
+		}
+		return reflect.Zero(reflect.SliceOf(reflect.TypeOf(elem))).Interface()
+	default:
+		return nil
+	}
+}
+
+// pendingCommitTimestamp is the string written as the commit timestamp in TIMESTAMP columns, the same as the function in DML.
+const pendingCommitTimestamp = "PENDING_COMMIT_TIMESTAMP()"
+
+// isCommitTimestamp reports whether v is the placeholder of the commit timestamp.
+func isCommitTimestamp(v interface{}) bool {
+	t, ok := v.(time.Time)
+	return ok && t == spanner.CommitTimestamp
+}
+
+// coerceValue converts a value loaded from yaml into the Go type corresponding to the column type,
+// so that values written in different forms (e.g. "2022-04-01" and civil.Date) can be compared.
+// Values of unsupported column types are returned as is.
+func coerceValue(typ *columnType, v interface{}) (interface{}, error) {
+	null := nullValue(typ)
+	if null == nil {
+		return v, nil
+	}
+
+	if v == nil {
+		return null, nil
+	}
+
+	if f, ok := v.(model.File); ok {
+		coerced, err := coerceFile(typ, f)
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert the file %s to %s: %w", f.Path, typ, err)
+		}
+		return coerced, nil
+	}
+
+	var (
+		coerced interface{}
+		err     error
+	)
+	switch typ.code {
+	case spannerpb.TypeCode_BOOL:
+		coerced, err = coerceBool(v)
+	case spannerpb.TypeCode_INT64:
+		coerced, err = coerceInt64(v)
+	case spannerpb.TypeCode_FLOAT64:
+		coerced, err = coerceFloat64(v)
+	case spannerpb.TypeCode_TIMESTAMP:
+		coerced, err = coerceTimestamp(v)
+	case spannerpb.TypeCode_DATE:
+		coerced, err = coerceDate(v)
+	case spannerpb.TypeCode_STRING:
+		coerced, err = coerceString(v)
+	case spannerpb.TypeCode_BYTES:
+		coerced, err = coerceBytes(v)
+	case spannerpb.TypeCode_NUMERIC:
+		coerced, err = coerceNumeric(v)
+	case spannerpb.TypeCode_JSON:
+		coerced, err = coerceJSON(v)
+	case typeCodeProto:
+		coerced, err = coerceProto(typ, v)
+	case typeCodeEnum:
+		coerced, err = coerceEnum(typ, v)
+	case typeCodeFloat32:
+		coerced, err = coerceFloat32(v)
+	case typeCodeInterval:
+		coerced, err = coerceInterval(v)
+	case spannerpb.TypeCode_ARRAY:
+		coerced, err = coerceArray(typ, reflect.TypeOf(null), v)
+	}
+	if err == nil {
+		err = checkLength(typ, coerced)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot convert %v to %s: %w", v, typ, err)
+	}
+
+	return coerced, nil
+}
+
+// checkLength checks the length of the STRING or BYTES value against the declared length, such as 36 of `STRING(36)`.
+func checkLength(typ *columnType, v interface{}) error {
+	if typ.length == 0 {
+		return nil
+	}
+
+	switch val := v.(type) {
+	case spanner.NullString:
+		if n := utf8.RuneCountInString(val.StringVal); int64(n) > typ.length {
+			return fmt.Errorf("the length %d characters exceeds the length %d", n, typ.length)
+		}
+	case []byte:
+		if int64(len(val)) > typ.length {
+			return fmt.Errorf("the size %d bytes exceeds the length %d", len(val), typ.length)
+		}
+	}
+
+	return nil
+}
+
+func coerceBool(v interface{}) (spanner.NullBool, error) {
+	switch b := v.(type) {
+	case bool:
+		return spanner.NullBool{Bool: b, Valid: true}, nil
+	case string:
+		parsed, err := strconv.ParseBool(b)
+		if err != nil {
+			return spanner.NullBool{}, err
+		}
+		return spanner.NullBool{Bool: parsed, Valid: true}, nil
+	default:
+		return spanner.NullBool{}, fmt.Errorf("unsupported type %T", v)
+	}
+}
+
+func coerceInt64(v interface{}) (spanner.NullInt64, error) {
+	switch n := v.(type) {
+	case int64:
+		return spanner.NullInt64{Int64: n, Valid: true}, nil
+	case int:
+		return spanner.NullInt64{Int64: int64(n), Valid: true}, nil
+	case uint64:
+		if n > math.MaxInt64 {
+			return spanner.NullInt64{}, fmt.Errorf("out of range")
+		}
+		return spanner.NullInt64{Int64: int64(n), Valid: true}, nil
+	case float64:
+		// math.MaxInt64 is rounded up to 2^63 as float64, which is out of the range.
+		if n != math.Trunc(n) || n < math.MinInt64 || n >= 1<<63 {
+			return spanner.NullInt64{}, fmt.Errorf("not an integer")
+		}
+		return spanner.NullInt64{Int64: int64(n), Valid: true}, nil
+	case string:
+		parsed, err := strconv.ParseInt(n, 10, 64)
+		if err != nil {
+			return spanner.NullInt64{}, err
+		}
+		return spanner.NullInt64{Int64: parsed, Valid: true}, nil
+	default:
+		return spanner.NullInt64{}, fmt.Errorf("unsupported type %T", v)
+	}
+}
+
+func coerceFloat64(v interface{}) (spanner.NullFloat64, error) {
+	switch f := v.(type) {
+	case float64:
+		return spanner.NullFloat64{Float64: f, Valid: true}, nil
+	case int64:
+		return spanner.NullFloat64{Float64: float64(f), Valid: true}, nil
+	case uint64:
+		return spanner.NullFloat64{Float64: float64(f), Valid: true}, nil
+	case string:
+		parsed, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return spanner.NullFloat64{}, err
+		}
+		return spanner.NullFloat64{Float64: parsed, Valid: true}, nil
+	default:
+		return spanner.NullFloat64{}, fmt.Errorf("unsupported type %T", v)
+	}
+}
+
+// coerceFloat32 rounds the value to FLOAT32 precision, so that it is comparable with the values read from Spanner.
+func coerceFloat32(v interface{}) (spanner.NullFloat64, error) {
+	f, err := coerceFloat64(v)
+	if err != nil {
+		return f, err
+	}
+
+	if !math.IsInf(f.Float64, 0) && !math.IsNaN(f.Float64) && math.Abs(f.Float64) > math.MaxFloat32 {
+		return spanner.NullFloat64{}, fmt.Errorf("out of range of FLOAT32")
+	}
+
+	return spanner.NullFloat64{Float64: float64(float32(f.Float64)), Valid: true}, nil
+}
+
+// coerceInterval converts an ISO 8601 duration into the canonical form.
+func coerceInterval(v interface{}) (spanner.NullString, error) {
+	s, ok := v.(string)
+	if !ok {
+		return spanner.NullString{}, fmt.Errorf("unsupported type %T", v)
+	}
+
+	iv, err := parseInterval(s)
+	if err != nil {
+		return spanner.NullString{}, err
+	}
+
+	return spanner.NullString{StringVal: iv.String(), Valid: true}, nil
+}
+
+func coerceTimestamp(v interface{}) (spanner.NullTime, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return spanner.NullTime{Time: t.UTC(), Valid: true}, nil
+	case string:
+		parsed, err := time.Parse(time.RFC3339Nano, t)
+		if err != nil {
+			return spanner.NullTime{}, err
+		}
+		return spanner.NullTime{Time: parsed.UTC(), Valid: true}, nil
+	default:
+		return spanner.NullTime{}, fmt.Errorf("unsupported type %T", v)
+	}
+}
+
+func coerceDate(v interface{}) (spanner.NullDate, error) {
+	switch d := v.(type) {
+	case civil.Date:
+		return spanner.NullDate{Date: d, Valid: true}, nil
+	case time.Time:
+		return spanner.NullDate{Date: civil.DateOf(d), Valid: true}, nil
+	case string:
+		parsed, err := civil.ParseDate(d)
+		if err != nil {
+			return spanner.NullDate{}, err
+		}
+		return spanner.NullDate{Date: parsed, Valid: true}, nil
+	default:
+		return spanner.NullDate{}, fmt.Errorf("unsupported type %T", v)
+	}
+}
+
+func coerceString(v interface{}) (spanner.NullString, error) {
+	switch s := v.(type) {
+	case string:
+		return spanner.NullString{StringVal: s, Valid: true}, nil
+	case int64, uint64, float64, bool:
+		return spanner.NullString{StringVal: fmt.Sprint(s), Valid: true}, nil
+	default:
+		return spanner.NullString{}, fmt.Errorf("unsupported type %T", v)
+	}
+}
+
+func coerceBytes(v interface{}) ([]byte, error) {
+	switch b := v.(type) {
+	case []byte:
+		return b, nil
+	case string:
+		return base64.StdEncoding.DecodeString(b)
+	default:
+		return nil, fmt.Errorf("unsupported type %T", v)
+	}
+}
+
+// coerceFile converts the content of the file into a value of BYTES, STRING or JSON, checking the declared length.
+func coerceFile(typ *columnType, f model.File) (interface{}, error) {
+	switch typ.code {
+	case spannerpb.TypeCode_BYTES:
+		return f.Content, checkLength(typ, f.Content)
+	case spannerpb.TypeCode_STRING:
+		if !utf8.Valid(f.Content) {
+			return nil, fmt.Errorf("not a valid UTF-8 text")
+		}
+		s := spanner.NullString{StringVal: string(f.Content), Valid: true}
+		return s, checkLength(typ, s)
+	case spannerpb.TypeCode_JSON:
+		return coerceJSON(string(f.Content))
+	default:
+		return nil, fmt.Errorf("files can be used only for BYTES, STRING and JSON")
+	}
+}
+
+func coerceNumeric(v interface{}) (spanner.NullNumeric, error) {
+	var s string
+	switch n := v.(type) {
+	case string:
+		s = n
+	case int64, uint64:
+		s = fmt.Sprint(n)
+	case float64:
+		s = strconv.FormatFloat(n, 'f', -1, 64)
+	case big.Rat:
+		return spanner.NullNumeric{Numeric: n, Valid: true}, nil
+	case *big.Rat:
+		return spanner.NullNumeric{Numeric: *n, Valid: true}, nil
+	default:
+		return spanner.NullNumeric{}, fmt.Errorf("unsupported type %T", v)
+	}
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return spanner.NullNumeric{}, fmt.Errorf("invalid numeric")
+	}
+
+	return spanner.NullNumeric{Numeric: *r, Valid: true}, nil
+}
+
+func coerceJSON(v interface{}) (spanner.NullJSON, error) {
+	if s, ok := v.(string); ok {
+		var parsed interface{}
+		if err := json.Unmarshal([]byte(s), &parsed); err != nil {
+			return spanner.NullJSON{}, err
+		}
+		return spanner.NullJSON{Value: parsed, Valid: true}, nil
+	}
+
+	// Normalize values such as yaml mappings through JSON so that they are comparable with the values read from Spanner.
+	b, err := json.Marshal(v)
+	if err != nil {
+		return spanner.NullJSON{}, err
+	}
+
+	var normalized interface{}
+	if err := json.Unmarshal(b, &normalized); err != nil {
+		return spanner.NullJSON{}, err
+	}
+
+	return spanner.NullJSON{Value: normalized, Valid: true}, nil
+}
+
+func coerceArray(typ *columnType, sliceType reflect.Type, v interface{}) (interface{}, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("not a list")
+	}
+
+	if typ.vectorLength > 0 && int64(rv.Len()) != typ.vectorLength {
+		return nil, fmt.Errorf("the length %d does not match vector_length=>%d", rv.Len(), typ.vectorLength)
+	}
+
+	slice := reflect.MakeSlice(sliceType, rv.Len(), rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		elem, err := coerceValue(typ.elem, rv.Index(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		slice.Index(i).Set(reflect.ValueOf(elem))
+	}
+
+	return slice.Interface(), nil
+}
+
+// decodeValue decodes a column value read from Spanner into the same Go type as coerceValue returns.
+func decodeValue(typ *columnType, v spanner.GenericColumnValue) (interface{}, error) {
+	null := nullValue(typ)
+	if null == nil {
+		return v, nil
+	}
+
+	if needsEncoding(typ) {
+		return decodeRawValue(typ, v.Value)
+	}
+
+	ptr := reflect.New(reflect.TypeOf(null))
+	if err := v.Decode(ptr.Interface()); err != nil {
+		return nil, fmt.Errorf("failed to decode %s value: %w", typ, err)
+	}
+
+	return ptr.Elem().Interface(), nil
+}
+
+// needsEncoding reports whether the column type is not supported by the client library,
+// so that the values need to be encoded for mutations and decoded from the raw values.
+func needsEncoding(typ *columnType) bool {
+	switch typ.code {
+	case spannerpb.TypeCode_ARRAY:
+		return needsEncoding(typ.elem)
+	case typeCodeProto, typeCodeEnum, typeCodeFloat32, typeCodeInterval:
+		return true
+	default:
+		return false
+	}
+}
+
+// decodeRawValue decodes a raw value of the column type into the same Go type as coerceValue returns.
+func decodeRawValue(typ *columnType, v *structpb.Value) (interface{}, error) {
+	if _, ok := v.GetKind().(*structpb.Value_NullValue); ok {
+		return nullValue(typ), nil
+	}
+
+	switch typ.code {
+	case spannerpb.TypeCode_ARRAY:
+		values := v.GetListValue().GetValues()
+		slice := reflect.MakeSlice(reflect.TypeOf(nullValue(typ)), len(values), len(values))
+		for i, ev := range values {
+			decoded, err := decodeRawValue(typ.elem, ev)
+			if err != nil {
+				return nil, err
+			}
+			slice.Index(i).Set(reflect.ValueOf(decoded))
+		}
+		return slice.Interface(), nil
+	case typeCodeProto:
+		b, err := base64.StdEncoding.DecodeString(v.GetStringValue())
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s value: %w", typ, err)
+		}
+		return canonicalProto(typ, b)
+	case typeCodeFloat32:
+		// NaN and infinities are encoded as strings.
+		if s, ok := v.GetKind().(*structpb.Value_StringValue); ok {
+			return coerceFloat32(s.StringValue)
+		}
+		return coerceFloat32(v.GetNumberValue())
+	case typeCodeEnum, typeCodeInterval:
+		return coerceValue(typ, v.GetStringValue())
+	default:
+		return nil, fmt.Errorf("unsupported type %s", typ)
+	}
+}
+
+// formatValue formats a value returned by coerceValue or decodeValue.
+// Equal values are formatted into the same string.
+func formatValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		if val == nil {
+			return "NULL"
+		}
+		return base64.StdEncoding.EncodeToString(val)
+	case spanner.NullString:
+		if !val.Valid {
+			return "NULL"
+		}
+		return strconv.Quote(val.StringVal)
+	case spanner.NullableValue:
+		if val.IsNull() {
+			return "NULL"
+		}
+		return fmt.Sprint(val)
+	case spanner.GenericColumnValue:
+		return val.Value.String()
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Slice {
+		if rv.IsNil() {
+			return "NULL"
+		}
+
+		elems := make([]string, rv.Len())
+		for i := range elems {
+			elems[i] = formatValue(rv.Index(i).Interface())
+		}
+		return "[" + strings.Join(elems, ", ") + "]"
+	}
+
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,167 @@
+package spanner
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
+	spannerpb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+	// Registers google.protobuf.Option for the PROTO columns.
+	_ "google.golang.org/protobuf/types/known/typepb"
+
+	"github.com/kauche/splanter/internal/model"
+)
+
+func TestCoerceValue(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		typ      string
+		value    interface{}
+		expected string
+	}{
+		{name: "string", typ: "STRING(MAX)", value: "FooBar", expected: `"FooBar"`},
+		{name: "integer string", typ: "STRING(36)", value: int64(123), expected: `"123"`},
+		{name: "bool", typ: "BOOL", value: true, expected: "true"},
+		{name: "int64", typ: "INT64", value: uint64(42), expected: "42"},
+		{name: "int64 min float", typ: "INT64", value: float64(-1 << 63), expected: "-9223372036854775808"},
+		{name: "float64", typ: "FLOAT64", value: float64(3.14159), expected: "3.14159"},
+		{name: "timestamp", typ: "TIMESTAMP", value: "2022-04-01T09:00:00+09:00", expected: "2022-04-01T00:00:00Z"},
+		{name: "date", typ: "DATE", value: "2022-04-01", expected: "2022-04-01"},
+		{name: "bytes", typ: "BYTES(MAX)", value: "aG9nZQ==", expected: "aG9nZQ=="},
+		{name: "numeric", typ: "NUMERIC", value: "1.5", expected: "1.500000000"},
+		{name: "json", typ: "JSON", value: `{"b": 1, "a": "x"}`, expected: `{"a":"x","b":1}`},
+		{name: "json mapping", typ: "JSON", value: map[string]interface{}{"b": uint64(1), "a": "x"}, expected: `{"a":"x","b":1}`},
+		{name: "null", typ: "STRING(MAX)", value: nil, expected: "NULL"},
+		{name: "array", typ: "ARRAY<DATE>", value: []string{"2022-04-01", "2022-04-02"}, expected: "[2022-04-01, 2022-04-02]"},
+		{name: "null array", typ: "ARRAY<INT64>", value: nil, expected: "NULL"},
+		{name: "empty array", typ: "ARRAY<INT64>", value: []string{}, expected: "[]"},
+		{name: "proto mapping", typ: "PROTO<google.protobuf.Option>", value: map[string]interface{}{"name": "a"}, expected: "CgFh"},
+		{name: "protojson", typ: "google.protobuf.Option", value: `{"name": "a"}`, expected: "CgFh"},
+		{name: "enum name", typ: "ENUM<google.protobuf.NullValue>", value: "NULL_VALUE", expected: "0"},
+		{name: "float32", typ: "FLOAT32", value: float64(3.14), expected: "3.140000104904175"},
+		{name: "bytes file", typ: "BYTES(4)", value: model.File{Path: "hoge", Content: []byte("hoge")}, expected: "aG9nZQ=="},
+		{name: "string file", typ: "STRING(4)", value: model.File{Path: "hoge", Content: []byte("ほげ")}, expected: `"ほげ"`},
+		{name: "json file", typ: "JSON", value: model.File{Path: "hoge", Content: []byte(`{"b": 1, "a": "x"}`)}, expected: `{"a":"x","b":1}`},
+		{name: "vector", typ: "ARRAY<FLOAT32>(vector_length=>2)", value: []float64{0.5, 2}, expected: "[0.5, 2]"},
+		{name: "float32 array", typ: "ARRAY<FLOAT32>", value: []int64{1, 2}, expected: "[1, 2]"},
+		{name: "interval", typ: "INTERVAL", value: "P1Y14M2DT25H0.5S", expected: `"P2Y2M2DT25H0.5S"`},
+		{name: "negative interval", typ: "INTERVAL", value: "-P1DT-1M", expected: `"P-1DT1M"`},
+		{name: "enum array", typ: "ARRAY<ENUM<google.protobuf.NullValue>>", value: []interface{}{"NULL_VALUE", uint64(0)}, expected: "[0, 0]"},
+		{name: "tagged json", typ: "JSON", value: spanner.NullJSON{Value: map[string]interface{}{"b": 1, "a": "x"}, Valid: true}, expected: `{"a":"x","b":1}`},
+		{name: "tagged numeric", typ: "NUMERIC", value: big.NewRat(3, 2), expected: "1.500000000"},
+		{name: "tagged dates", typ: "ARRAY<DATE>", value: []civil.Date{{Year: 2022, Month: 4, Day: 1}}, expected: "[2022-04-01]"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			typ, err := parseColumnType(tt.typ)
+			if err != nil {
+				t.Fatalf("failed to parse type: %s", err)
+			}
+			new(DB).resolveProtoType(typ)
+
+			coerced, err := coerceValue(typ, tt.value)
+			if err != nil {
+				t.Fatalf("failed to coerce: %s", err)
+			}
+
+			if actual := formatValue(coerced); actual != tt.expected {
+				t.Errorf("expected %s, but got %s", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestCoerceValueErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		typ   string
+		value interface{}
+	}{
+		{name: "long string", typ: "STRING(4)", value: "ほげほげほ"},
+		{name: "long bytes", typ: "BYTES(4)", value: "aG9nZWg="},
+		{name: "long string file", typ: "STRING(4)", value: model.File{Path: "hoge", Content: []byte("ほげほげほ")}},
+		{name: "long bytes file", typ: "BYTES(4)", value: model.File{Path: "hoge", Content: []byte("hogeh")}},
+		{name: "long string in array", typ: "ARRAY<STRING(4)>", value: []string{"hoge", "hogeh"}},
+		{name: "long vector", typ: "ARRAY<FLOAT32>(vector_length=>2)", value: []float64{0.5, 2, 3}},
+		{name: "int64 out of range float", typ: "INT64", value: float64(1 << 63)},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			typ, err := parseColumnType(tt.typ)
+			if err != nil {
+				t.Fatalf("failed to parse type: %s", err)
+			}
+
+			if coerced, err := coerceValue(typ, tt.value); err == nil {
+				t.Errorf("expected an error, but got %s", formatValue(coerced))
+			}
+		})
+	}
+}
+
+func TestDecodeValue(t *testing.T) {
+	t.Parallel()
+
+	typ, err := parseColumnType("DATE")
+	if err != nil {
+		t.Fatalf("failed to parse type: %s", err)
+	}
+
+	decoded, err := decodeValue(typ, spanner.GenericColumnValue{
+		Type:  &spannerpb.Type{Code: spannerpb.TypeCode_DATE},
+		Value: structpb.NewStringValue("2022-04-01"),
+	})
+	if err != nil {
+		t.Fatalf("failed to decode: %s", err)
+	}
+
+	coerced, err := coerceValue(typ, civil.DateOf(time.Date(2022, time.April, 1, 0, 0, 0, 0, time.UTC)))
+	if err != nil {
+		t.Fatalf("failed to coerce: %s", err)
+	}
+
+	if formatValue(decoded) != formatValue(coerced) {
+		t.Errorf("expected %s, but got %s", formatValue(coerced), formatValue(decoded))
+	}
+}
+
+func TestDecodeRawValue(t *testing.T) {
+	t.Parallel()
+
+	typ, err := parseColumnType("ARRAY<FLOAT32>")
+	if err != nil {
+		t.Fatalf("failed to parse type: %s", err)
+	}
+
+	decoded, err := decodeValue(typ, spanner.GenericColumnValue{
+		Type:  &spannerpb.Type{Code: spannerpb.TypeCode_ARRAY, ArrayElementType: &spannerpb.Type{Code: typeCodeFloat32}},
+		Value: structpb.NewListValue(&structpb.ListValue{Values: []*structpb.Value{structpb.NewNumberValue(3.14), structpb.NewStringValue("NaN"), structpb.NewNullValue()}}),
+	})
+	if err != nil {
+		t.Fatalf("failed to decode: %s", err)
+	}
+
+	coerced, err := coerceValue(typ, []interface{}{float64(3.14), "NaN", nil})
+	if err != nil {
+		t.Fatalf("failed to coerce: %s", err)
+	}
+
+	if formatValue(decoded) != formatValue(coerced) {
+		t.Errorf("expected %s, but got %s", formatValue(coerced), formatValue(decoded))
+	}
+}
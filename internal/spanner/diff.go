@@ -0,0 +1,211 @@
+package spanner
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/spanner"
+
+	"github.com/kauche/splanter/internal/model"
+)
+
+// TableDiff is the difference between the records of a table and the rows in the database.
+type TableDiff struct {
+	Table string
+
+	// Missing is the records which do not exist in the database.
+	Missing []*RowDiff
+	// Extra is the rows which exist only in the database.
+	Extra []*RowDiff
+	// Changed is the records whose values differ from the rows in the database.
+	Changed []*RowDiff
+
+	// Skipped is the records which are not compared because their keys are unknown until they are saved.
+	Skipped []*SkippedRecord
+}
+
+// SkippedRecord is a record which is not compared, and the reason.
+type SkippedRecord struct {
+	Position model.Position
+	Reason   string
+}
+
+type RowDiff struct {
+	Key     string
+	Columns []*ColumnDiff
+}
+
+// ColumnDiff is a column value of the records (Expected) and the database (Actual).
+// Either of them is empty for missing and extra rows.
+type ColumnDiff struct {
+	Column   string
+	Expected string
+	Actual   string
+}
+
+func (t *TableDiff) Empty() bool {
+	return len(t.Missing) == 0 && len(t.Extra) == 0 && len(t.Changed) == 0
+}
+
+// Diff compares the records with the rows having the same primary keys in the database, for each table name.
+// Only the columns specified in each record, or filled by the rules of the tables as Save does, are compared.
+// Records whose keys are generated or refer to other records are skipped, since they are unknown until saved.
+// When extra is true, rows which exist only in the database are also reported.
+func (d *DB) Diff(ctx context.Context, tables []*model.Table, extra bool) ([]*TableDiff, error) {
+	tableNames := make([]string, len(tables))
+	for i, t := range tables {
+		tableNames[i] = t.Name
+	}
+
+	schemas, err := d.selectTableSchemas(ctx, tableNames)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get table schemas: %w", err)
+	}
+
+	// The columns filled by the volatile rules such as `!now` differ from the rows written by the previous load.
+	volatile := volatileColumns(tables, schemas)
+	applyColumnRules(tables, schemas)
+	applyCommitTimestamps(tables, schemas)
+
+	// The records of a table may be in multiple files, which are compared with the rows at once
+	// so that the rows of the other files are not reported as extra.
+	var grouped []*model.Table
+	byName := make(map[string]*model.Table)
+	for _, table := range tables {
+		g, ok := byName[table.Name]
+		if !ok {
+			g = &model.Table{Name: table.Name}
+			byName[table.Name] = g
+			grouped = append(grouped, g)
+		}
+		g.Records = append(g.Records, table.Records...)
+	}
+
+	tx := d.client.ReadOnlyTransaction()
+	defer tx.Close()
+
+	diffs := make([]*TableDiff, len(grouped))
+	for i, table := range grouped {
+		ts := schemas[table.Name]
+		diff := &TableDiff{Table: table.Name}
+
+		var (
+			records []*model.Record
+			keys    []spanner.Key
+		)
+		recordKeys := make(map[string]bool, len(table.Records))
+		for _, record := range table.Records {
+			for column := range record.Values {
+				if _, exists := ts.columnMap[column]; !exists {
+					return nil, fmt.Errorf("column %s is not found in %s", column, table.Name)
+				}
+			}
+
+			if ts.keyResolvedOnSave(record) {
+				diff.Skipped = append(diff.Skipped, &SkippedRecord{Position: record.Position, Reason: "the key is resolved on save"})
+				continue
+			}
+
+			key, err := ts.recordKey(record)
+			if err != nil {
+				diff.Skipped = append(diff.Skipped, &SkippedRecord{Position: record.Position, Reason: err.Error()})
+				continue
+			}
+			records = append(records, record)
+			keys = append(keys, key)
+			recordKeys[formatKey(key)] = true
+		}
+
+		keySet := spanner.KeySetFromKeys(keys...)
+		if extra {
+			keySet = spanner.AllKeys()
+		}
+
+		rows := make(map[string][]interface{})
+		err := tx.Read(ctx, table.Name, keySet, ts.columnNames()).Do(func(row *spanner.Row) error {
+			values, err := ts.readRow(row)
+			if err != nil {
+				return err
+			}
+
+			key := formatKey(ts.rowKey(values))
+			rows[key] = values
+
+			if !recordKeys[key] {
+				rd := &RowDiff{Key: key}
+				for j, c := range ts.columns {
+					rd.Columns = append(rd.Columns, &ColumnDiff{Column: c.name, Actual: formatValue(values[j])})
+				}
+				diff.Extra = append(diff.Extra, rd)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", table.Name, err)
+		}
+
+		for j, record := range records {
+			key := formatKey(keys[j])
+			values, ok := rows[key]
+
+			rd := &RowDiff{Key: key}
+			for k, c := range ts.columns {
+				v, specified := record.Values[c.name]
+				// The commit timestamp is unknown until the record is written.
+				if !specified || isCommitTimestamp(v) || volatile[record][c.name] {
+					continue
+				}
+
+				coerced, err := coerceValue(c.typ, v)
+				if err != nil {
+					return nil, fmt.Errorf("invalid value of %s.%s: %w", table.Name, c.name, err)
+				}
+
+				cd := &ColumnDiff{Column: c.name, Expected: formatValue(coerced)}
+				if ok {
+					cd.Actual = formatValue(values[k])
+					if cd.Actual == cd.Expected {
+						continue
+					}
+				}
+				rd.Columns = append(rd.Columns, cd)
+			}
+
+			switch {
+			case !ok:
+				diff.Missing = append(diff.Missing, rd)
+			case len(rd.Columns) > 0:
+				diff.Changed = append(diff.Changed, rd)
+			}
+		}
+
+		diffs[i] = diff
+	}
+
+	return diffs, nil
+}
+
+// volatileColumns returns the columns of each record which are going to be filled by the volatile rules.
+func volatileColumns(tables []*model.Table, schemas map[string]*tableSchema) map[*model.Record]map[string]bool {
+	columns := make(map[*model.Record]map[string]bool)
+	for _, table := range tables {
+		for _, c := range schemas[table.Name].columns {
+			if rule := columnRule(table, c); rule == nil || !rule.Volatile {
+				continue
+			}
+
+			for _, record := range table.Records {
+				if _, ok := record.Values[c.name]; ok {
+					continue
+				}
+				if columns[record] == nil {
+					columns[record] = make(map[string]bool)
+				}
+				columns[record][c.name] = true
+			}
+		}
+	}
+
+	return columns
+}
@@ -1,6 +1,11 @@
 package spanner
 
-import "cloud.google.com/go/spanner"
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/spanner"
+)
 
 type informationSchemaTable struct {
 	TableName       spanner.NullString `spanner:"TABLE_NAME"`
@@ -8,3 +13,210 @@ type informationSchemaTable struct {
 
 	numDependentParents uint
 }
+
+type informationSchemaColumn struct {
+	TableName   spanner.NullString `spanner:"TABLE_NAME"`
+	ColumnName  spanner.NullString `spanner:"COLUMN_NAME"`
+	SpannerType spanner.NullString `spanner:"SPANNER_TYPE"`
+	IsNullable  spanner.NullString `spanner:"IS_NULLABLE"`
+	IsGenerated spanner.NullString `spanner:"IS_GENERATED"`
+	HasDefault  bool               `spanner:"HAS_DEFAULT"`
+}
+
+type informationSchemaIndexColumn struct {
+	TableName  spanner.NullString `spanner:"TABLE_NAME"`
+	ColumnName spanner.NullString `spanner:"COLUMN_NAME"`
+}
+
+func (d *DB) selectColumns(ctx context.Context, tableNames []string) (map[string][]*informationSchemaColumn, error) {
+	statement := spanner.Statement{
+		SQL: `SELECT TABLE_NAME, COLUMN_NAME, SPANNER_TYPE, IS_NULLABLE, IS_GENERATED, COLUMN_DEFAULT IS NOT NULL AS HAS_DEFAULT FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_SCHEMA = "" AND TABLE_NAME IN UNNEST (@tables) ORDER BY TABLE_NAME, ORDINAL_POSITION`,
+		Params: map[string]interface{}{
+			"tables": tableNames,
+		},
+	}
+
+	columns := make(map[string][]*informationSchemaColumn)
+	err := d.client.Single().Query(ctx, statement).Do(func(row *spanner.Row) error {
+		isc := new(informationSchemaColumn)
+		if err := row.ToStruct(isc); err != nil {
+			return fmt.Errorf("failed to populate struct by rows: %w", err)
+		}
+
+		columns[isc.TableName.StringVal] = append(columns[isc.TableName.StringVal], isc)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to select INFORMATION_SCHEMA.COLUMNS: %w", err)
+	}
+
+	return columns, nil
+}
+
+func (d *DB) selectPrimaryKeyColumns(ctx context.Context, tableNames []string) (map[string][]string, error) {
+	statement := spanner.Statement{
+		SQL: `SELECT TABLE_NAME, COLUMN_NAME FROM INFORMATION_SCHEMA.INDEX_COLUMNS WHERE TABLE_SCHEMA = "" AND INDEX_NAME = "PRIMARY_KEY" AND TABLE_NAME IN UNNEST (@tables) ORDER BY TABLE_NAME, ORDINAL_POSITION`,
+		Params: map[string]interface{}{
+			"tables": tableNames,
+		},
+	}
+
+	keys := make(map[string][]string)
+	err := d.client.Single().Query(ctx, statement).Do(func(row *spanner.Row) error {
+		isic := new(informationSchemaIndexColumn)
+		if err := row.ToStruct(isic); err != nil {
+			return fmt.Errorf("failed to populate struct by rows: %w", err)
+		}
+
+		keys[isic.TableName.StringVal] = append(keys[isic.TableName.StringVal], isic.ColumnName.StringVal)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to select INFORMATION_SCHEMA.INDEX_COLUMNS: %w", err)
+	}
+
+	return keys, nil
+}
+
+type informationSchemaUniqueIndexColumn struct {
+	TableName      spanner.NullString `spanner:"TABLE_NAME"`
+	IndexName      spanner.NullString `spanner:"INDEX_NAME"`
+	IsNullFiltered bool               `spanner:"IS_NULL_FILTERED"`
+	ColumnName     spanner.NullString `spanner:"COLUMN_NAME"`
+}
+
+// selectUniqueIndexColumns returns the key columns of each unique secondary index for each table.
+func (d *DB) selectUniqueIndexColumns(ctx context.Context, tableNames []string) (map[string][]*informationSchemaUniqueIndexColumn, error) {
+	statement := spanner.Statement{
+		SQL: `SELECT i.TABLE_NAME, i.INDEX_NAME, i.IS_NULL_FILTERED, ic.COLUMN_NAME
+FROM INFORMATION_SCHEMA.INDEXES AS i
+JOIN INFORMATION_SCHEMA.INDEX_COLUMNS AS ic ON ic.TABLE_SCHEMA = i.TABLE_SCHEMA AND ic.TABLE_NAME = i.TABLE_NAME AND ic.INDEX_NAME = i.INDEX_NAME
+WHERE i.TABLE_SCHEMA = "" AND i.INDEX_TYPE = "INDEX" AND i.IS_UNIQUE AND ic.ORDINAL_POSITION IS NOT NULL AND i.TABLE_NAME IN UNNEST (@tables)
+ORDER BY i.TABLE_NAME, i.INDEX_NAME, ic.ORDINAL_POSITION`,
+		Params: map[string]interface{}{
+			"tables": tableNames,
+		},
+	}
+
+	columns := make(map[string][]*informationSchemaUniqueIndexColumn)
+	err := d.client.Single().Query(ctx, statement).Do(func(row *spanner.Row) error {
+		isuic := new(informationSchemaUniqueIndexColumn)
+		if err := row.ToStruct(isuic); err != nil {
+			return fmt.Errorf("failed to populate struct by rows: %w", err)
+		}
+
+		columns[isuic.TableName.StringVal] = append(columns[isuic.TableName.StringVal], isuic)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to select INFORMATION_SCHEMA.INDEXES: %w", err)
+	}
+
+	return columns, nil
+}
+
+type informationSchemaColumnOption struct {
+	TableName   spanner.NullString `spanner:"TABLE_NAME"`
+	ColumnName  spanner.NullString `spanner:"COLUMN_NAME"`
+	OptionValue spanner.NullString `spanner:"OPTION_VALUE"`
+}
+
+// selectCommitTimestampColumns returns the columns which have `allow_commit_timestamp=true` for each table.
+func (d *DB) selectCommitTimestampColumns(ctx context.Context, tableNames []string) (map[string]map[string]bool, error) {
+	statement := spanner.Statement{
+		SQL: `SELECT TABLE_NAME, COLUMN_NAME, OPTION_VALUE FROM INFORMATION_SCHEMA.COLUMN_OPTIONS WHERE TABLE_SCHEMA = "" AND OPTION_NAME = "allow_commit_timestamp" AND TABLE_NAME IN UNNEST (@tables)`,
+		Params: map[string]interface{}{
+			"tables": tableNames,
+		},
+	}
+
+	columns := make(map[string]map[string]bool)
+	err := d.client.Single().Query(ctx, statement).Do(func(row *spanner.Row) error {
+		isco := new(informationSchemaColumnOption)
+		if err := row.ToStruct(isco); err != nil {
+			return fmt.Errorf("failed to populate struct by rows: %w", err)
+		}
+
+		if isco.OptionValue.StringVal != "TRUE" {
+			return nil
+		}
+
+		if _, ok := columns[isco.TableName.StringVal]; !ok {
+			columns[isco.TableName.StringVal] = make(map[string]bool)
+		}
+		columns[isco.TableName.StringVal][isco.ColumnName.StringVal] = true
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to select INFORMATION_SCHEMA.COLUMN_OPTIONS: %w", err)
+	}
+
+	return columns, nil
+}
+
+type informationSchemaForeignKeyColumn struct {
+	ConstraintName       spanner.NullString `spanner:"CONSTRAINT_NAME"`
+	TableName            spanner.NullString `spanner:"TABLE_NAME"`
+	ColumnName           spanner.NullString `spanner:"COLUMN_NAME"`
+	ReferencedTableName  spanner.NullString `spanner:"REFERENCED_TABLE_NAME"`
+	ReferencedColumnName spanner.NullString `spanner:"REFERENCED_COLUMN_NAME"`
+}
+
+// foreignKey is a foreign key constraint from columns of table to referencedColumns of referencedTable.
+type foreignKey struct {
+	name              string
+	table             string
+	columns           []string
+	referencedTable   string
+	referencedColumns []string
+}
+
+// selectForeignKeys returns the foreign keys of the tables.
+func (d *DB) selectForeignKeys(ctx context.Context, tableNames []string) ([]*foreignKey, error) {
+	statement := spanner.Statement{
+		SQL: `SELECT rc.CONSTRAINT_NAME, kcu.TABLE_NAME, kcu.COLUMN_NAME, rkcu.TABLE_NAME AS REFERENCED_TABLE_NAME, rkcu.COLUMN_NAME AS REFERENCED_COLUMN_NAME
+FROM INFORMATION_SCHEMA.REFERENTIAL_CONSTRAINTS AS rc
+JOIN INFORMATION_SCHEMA.KEY_COLUMN_USAGE AS kcu ON kcu.CONSTRAINT_SCHEMA = rc.CONSTRAINT_SCHEMA AND kcu.CONSTRAINT_NAME = rc.CONSTRAINT_NAME
+JOIN INFORMATION_SCHEMA.KEY_COLUMN_USAGE AS rkcu ON rkcu.CONSTRAINT_SCHEMA = rc.UNIQUE_CONSTRAINT_SCHEMA AND rkcu.CONSTRAINT_NAME = rc.UNIQUE_CONSTRAINT_NAME AND rkcu.ORDINAL_POSITION = kcu.POSITION_IN_UNIQUE_CONSTRAINT
+WHERE rc.CONSTRAINT_SCHEMA = "" AND kcu.TABLE_NAME IN UNNEST (@tables)
+ORDER BY rc.CONSTRAINT_NAME, kcu.ORDINAL_POSITION`,
+		Params: map[string]interface{}{
+			"tables": tableNames,
+		},
+	}
+
+	var (
+		fks   []*foreignKey
+		fkMap = make(map[string]*foreignKey)
+	)
+	err := d.client.Single().Query(ctx, statement).Do(func(row *spanner.Row) error {
+		isfkc := new(informationSchemaForeignKeyColumn)
+		if err := row.ToStruct(isfkc); err != nil {
+			return fmt.Errorf("failed to populate struct by rows: %w", err)
+		}
+
+		fk, ok := fkMap[isfkc.ConstraintName.StringVal]
+		if !ok {
+			fk = &foreignKey{
+				name:            isfkc.ConstraintName.StringVal,
+				table:           isfkc.TableName.StringVal,
+				referencedTable: isfkc.ReferencedTableName.StringVal,
+			}
+			fkMap[fk.name] = fk
+			fks = append(fks, fk)
+		}
+		fk.columns = append(fk.columns, isfkc.ColumnName.StringVal)
+		fk.referencedColumns = append(fk.referencedColumns, isfkc.ReferencedColumnName.StringVal)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to select INFORMATION_SCHEMA.REFERENTIAL_CONSTRAINTS: %w", err)
+	}
+
+	return fks, nil
+}
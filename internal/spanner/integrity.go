@@ -0,0 +1,195 @@
+package spanner
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+
+	"github.com/kauche/splanter/internal/model"
+)
+
+// referenceBatchSize is the maximum number of keys looked up in the database with a query.
+const referenceBatchSize = 100
+
+// orphan is a record whose values of the foreign key columns are not found in the records to be written.
+type orphan struct {
+	table  *model.Table
+	record *model.Record
+	fk     *foreignKey
+	// values is the formatted values of the foreign key columns, and params is the coerced ones.
+	values string
+	params []interface{}
+}
+
+// checkReferences fails if records refer to rows which are found neither in the records nor in the database,
+// by the foreign keys or as interleaved children, listing all such records with their locations.
+// Spanner would report them only as an error of the whole commit.
+func (d *DB) checkReferences(ctx context.Context, tables []*model.Table, schemas map[string]*tableSchema, deps *dependencies) error {
+	tableNames := make([]string, 0, len(schemas))
+	for name := range schemas {
+		tableNames = append(tableNames, name)
+	}
+	sort.Strings(tableNames)
+
+	parentNames := make([]string, 0, len(deps.parents))
+	for _, parent := range deps.parents {
+		parentNames = append(parentNames, parent)
+	}
+
+	parentKeys, err := d.selectPrimaryKeyColumns(ctx, parentNames)
+	if err != nil {
+		return fmt.Errorf("failed to get primary keys: %w", err)
+	}
+
+	// A child row refers to the parent row by the key columns of the parent, which the child has as the prefix of its key.
+	var refs []*foreignKey
+	for _, name := range tableNames {
+		if parent, ok := deps.parents[name]; ok && len(parentKeys[parent]) > 0 {
+			refs = append(refs, &foreignKey{
+				name:              "INTERLEAVE IN PARENT " + parent,
+				table:             name,
+				columns:           parentKeys[parent],
+				referencedTable:   parent,
+				referencedColumns: parentKeys[parent],
+			})
+		}
+	}
+	refs = append(refs, deps.fks...)
+
+	var orphans []*orphan
+	for _, fk := range refs {
+		written := make(map[string]bool)
+		for _, table := range tables {
+			if table.Name != fk.referencedTable {
+				continue
+			}
+			for _, record := range table.Records {
+				if v, ok := foreignKeyValue(schemas[table.Name], record, fk.referencedColumns); ok {
+					written[v] = true
+				}
+			}
+		}
+
+		for _, table := range tables {
+			if table.Name != fk.table {
+				continue
+			}
+
+			ts := schemas[table.Name]
+			for _, record := range table.Records {
+				v, ok := foreignKeyValue(ts, record, fk.columns)
+				if !ok || written[v] {
+					continue
+				}
+
+				params := make([]interface{}, len(fk.columns))
+				for i, c := range fk.columns {
+					params[i], _ = coerceValue(ts.columnMap[c].typ, record.Values[c])
+				}
+				orphans = append(orphans, &orphan{table: table, record: record, fk: fk, values: v, params: params})
+			}
+		}
+	}
+
+	if len(orphans) == 0 {
+		return nil
+	}
+
+	existing, err := d.selectReferencedValues(ctx, schemas, orphans)
+	if err != nil {
+		return err
+	}
+
+	var messages []string
+	for _, o := range orphans {
+		if !existing[o.fk][o.values] {
+			err := fmt.Errorf("%s refers to %s (%s) by %s, which is not found", o.table.Name, o.fk.referencedTable, o.values, o.fk.name)
+			messages = append(messages, recordError(o.table, o.record, o.fk.columns[0], err).Error())
+		}
+	}
+
+	if len(messages) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%d records refer to rows which are not found:\n%s", len(messages), strings.Join(messages, "\n"))
+}
+
+// selectReferencedValues looks up the values referred by the orphans in the database, in batches for each foreign key.
+func (d *DB) selectReferencedValues(ctx context.Context, schemas map[string]*tableSchema, orphans []*orphan) (map[*foreignKey]map[string]bool, error) {
+	var fks []*foreignKey
+	keys := make(map[*foreignKey][][]interface{})
+	seen := make(map[*foreignKey]map[string]bool)
+	for _, o := range orphans {
+		if seen[o.fk] == nil {
+			seen[o.fk] = make(map[string]bool)
+			fks = append(fks, o.fk)
+		}
+		if !seen[o.fk][o.values] {
+			seen[o.fk][o.values] = true
+			keys[o.fk] = append(keys[o.fk], o.params)
+		}
+	}
+
+	existing := make(map[*foreignKey]map[string]bool)
+	for _, fk := range fks {
+		existing[fk] = make(map[string]bool)
+
+		ts := schemas[fk.table]
+		quoted := make([]string, len(fk.referencedColumns))
+		for i, c := range fk.referencedColumns {
+			quoted[i] = "`" + c + "`"
+		}
+
+		for start := 0; start < len(keys[fk]); start += referenceBatchSize {
+			end := start + referenceBatchSize
+			if end > len(keys[fk]) {
+				end = len(keys[fk])
+			}
+
+			params := make(map[string]interface{})
+			conditions := make([]string, 0, end-start)
+			for i, key := range keys[fk][start:end] {
+				equals := make([]string, len(key))
+				for j, v := range key {
+					param := fmt.Sprintf("p%d_%d", i, j)
+					params[param] = v
+					equals[j] = fmt.Sprintf("%s = @%s", quoted[j], param)
+				}
+				conditions = append(conditions, "("+strings.Join(equals, " AND ")+")")
+			}
+
+			statement := spanner.Statement{
+				SQL:    fmt.Sprintf("SELECT %s FROM `%s` WHERE %s", strings.Join(quoted, ", "), fk.referencedTable, strings.Join(conditions, " OR ")),
+				Params: params,
+			}
+
+			err := d.client.Single().Query(ctx, statement).Do(func(row *spanner.Row) error {
+				parts := make([]string, len(fk.columns))
+				for i, c := range fk.columns {
+					var gcv spanner.GenericColumnValue
+					if err := row.Column(i, &gcv); err != nil {
+						return fmt.Errorf("failed to read column %s: %w", fk.referencedColumns[i], err)
+					}
+
+					v, err := decodeValue(ts.columnMap[c].typ, gcv)
+					if err != nil {
+						return fmt.Errorf("failed to decode column %s: %w", fk.referencedColumns[i], err)
+					}
+					parts[i] = formatValue(v)
+				}
+				existing[fk][strings.Join(parts, ", ")] = true
+
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to select the rows of %s referred by %s: %w", fk.referencedTable, fk.name, err)
+			}
+		}
+	}
+
+	return existing, nil
+}
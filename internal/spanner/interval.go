@@ -0,0 +1,146 @@
+package spanner
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// interval is a value of INTERVAL, which consists of months, days and nanoseconds as Spanner does.
+type interval struct {
+	months int64
+	days   int64
+	nanos  int64
+}
+
+// parseInterval parses an ISO 8601 duration such as `P1Y2M3DT4H5M6.5S`.
+// Each component may be negative (e.g. `P-1Y2M`), and a leading `-` negates all of them.
+func parseInterval(s string) (interval, error) {
+	var iv interval
+
+	rest := s
+	negative := strings.HasPrefix(rest, "-")
+	rest = strings.TrimPrefix(rest, "-")
+
+	if !strings.HasPrefix(rest, "P") || len(rest) == 1 {
+		return iv, fmt.Errorf("invalid ISO 8601 duration %q", s)
+	}
+	rest = rest[1:]
+
+	inTime := false
+	for rest != "" {
+		if rest[0] == 'T' {
+			if inTime || len(rest) == 1 {
+				return iv, fmt.Errorf("invalid ISO 8601 duration %q", s)
+			}
+			inTime = true
+			rest = rest[1:]
+			continue
+		}
+
+		i := strings.IndexAny(rest, "YMWDHS")
+		if i <= 0 {
+			return iv, fmt.Errorf("invalid ISO 8601 duration %q", s)
+		}
+		number, unit := rest[:i], rest[i]
+		rest = rest[i+1:]
+
+		if unit == 'S' && inTime {
+			nanos, err := parseSeconds(number)
+			if err != nil {
+				return iv, fmt.Errorf("invalid seconds of ISO 8601 duration %q: %w", s, err)
+			}
+			iv.nanos += nanos
+			continue
+		}
+
+		n, err := strconv.ParseInt(number, 10, 64)
+		if err != nil {
+			return iv, fmt.Errorf("invalid ISO 8601 duration %q: %w", s, err)
+		}
+
+		switch {
+		case unit == 'Y' && !inTime:
+			iv.months += n * 12
+		case unit == 'M' && !inTime:
+			iv.months += n
+		case unit == 'W' && !inTime:
+			iv.days += n * 7
+		case unit == 'D' && !inTime:
+			iv.days += n
+		case unit == 'H' && inTime:
+			iv.nanos += n * int64(time.Hour)
+		case unit == 'M' && inTime:
+			iv.nanos += n * int64(time.Minute)
+		default:
+			return iv, fmt.Errorf("invalid ISO 8601 duration %q", s)
+		}
+	}
+
+	if negative {
+		iv = interval{months: -iv.months, days: -iv.days, nanos: -iv.nanos}
+	}
+
+	return iv, nil
+}
+
+// parseSeconds parses seconds with up to 9 fractional digits into nanoseconds.
+func parseSeconds(s string) (int64, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, fmt.Errorf("invalid number %s", s)
+	}
+
+	r.Mul(r, big.NewRat(int64(time.Second), 1))
+	if !r.IsInt() {
+		return 0, fmt.Errorf("more than 9 fractional digits")
+	}
+	if !r.Num().IsInt64() {
+		return 0, fmt.Errorf("out of range")
+	}
+
+	return r.Num().Int64(), nil
+}
+
+// String formats the interval into the canonical ISO 8601 duration, so that equal intervals are formatted into the same string.
+func (iv interval) String() string {
+	var b strings.Builder
+	b.WriteString("P")
+
+	if years := iv.months / 12; years != 0 {
+		fmt.Fprintf(&b, "%dY", years)
+	}
+	if months := iv.months % 12; months != 0 {
+		fmt.Fprintf(&b, "%dM", months)
+	}
+	if iv.days != 0 {
+		fmt.Fprintf(&b, "%dD", iv.days)
+	}
+
+	if iv.nanos != 0 {
+		b.WriteString("T")
+
+		nanos := iv.nanos
+		if hours := nanos / int64(time.Hour); hours != 0 {
+			fmt.Fprintf(&b, "%dH", hours)
+		}
+		nanos %= int64(time.Hour)
+		if minutes := nanos / int64(time.Minute); minutes != 0 {
+			fmt.Fprintf(&b, "%dM", minutes)
+		}
+		nanos %= int64(time.Minute)
+		if nanos != 0 {
+			seconds := new(big.Rat).SetFrac64(nanos, int64(time.Second)).FloatString(9)
+			seconds = strings.TrimRight(strings.TrimRight(seconds, "0"), ".")
+			fmt.Fprintf(&b, "%sS", seconds)
+		}
+	}
+
+	if b.Len() == 1 {
+		return "P0D"
+	}
+
+	return b.String()
+}
@@ -0,0 +1,116 @@
+package spanner
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/api/impersonate"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+type Option func(*options)
+
+type options struct {
+	credentialsFile           string
+	impersonateServiceAccount string
+	endpoint                  string
+	emulatorHost              string
+	databaseRole              string
+	protoDescriptorSet        string
+}
+
+// WithCredentialsFile makes the client authenticate with the service account key or the credentials JSON file at path instead of Application Default Credentials.
+func WithCredentialsFile(path string) Option {
+	return func(o *options) {
+		o.credentialsFile = path
+	}
+}
+
+// WithImpersonateServiceAccount makes the client act as the given service account, using the base credentials to mint its tokens.
+func WithImpersonateServiceAccount(email string) Option {
+	return func(o *options) {
+		o.impersonateServiceAccount = email
+	}
+}
+
+// WithEndpoint overrides the Spanner API endpoint (e.g. a regional or private endpoint).
+func WithEndpoint(endpoint string) Option {
+	return func(o *options) {
+		o.endpoint = endpoint
+	}
+}
+
+// WithEmulatorHost connects to the Spanner emulator at host without authentication, as SPANNER_EMULATOR_HOST does.
+func WithEmulatorHost(host string) Option {
+	return func(o *options) {
+		o.emulatorHost = host
+	}
+}
+
+// WithDatabaseRole makes every operation run as the given database role for fine-grained access control.
+func WithDatabaseRole(role string) Option {
+	return func(o *options) {
+		o.databaseRole = role
+	}
+}
+
+// WithProtoDescriptorSet reads the descriptors of PROTO and ENUM columns from the FileDescriptorSet file at path,
+// instead of the types registered in the Go program.
+func WithProtoDescriptorSet(path string) Option {
+	return func(o *options) {
+		o.protoDescriptorSet = path
+	}
+}
+
+func (o *options) protoFiles() (*protoregistry.Files, error) {
+	if o.protoDescriptorSet == "" {
+		return nil, nil
+	}
+
+	return readProtoDescriptorSet(o.protoDescriptorSet)
+}
+
+func (o *options) clientConfig() spanner.ClientConfig {
+	return spanner.ClientConfig{
+		DatabaseRole: o.databaseRole,
+	}
+}
+
+func (o *options) clientOptions(ctx context.Context) ([]option.ClientOption, error) {
+	if o.emulatorHost != "" {
+		return []option.ClientOption{
+			option.WithEndpoint(o.emulatorHost),
+			option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+			option.WithoutAuthentication(),
+		}, nil
+	}
+
+	var opts []option.ClientOption
+
+	if o.endpoint != "" {
+		opts = append(opts, option.WithEndpoint(o.endpoint))
+	}
+
+	var credsOpts []option.ClientOption
+	if o.credentialsFile != "" {
+		credsOpts = append(credsOpts, option.WithCredentialsFile(o.credentialsFile))
+	}
+
+	if o.impersonateServiceAccount == "" {
+		return append(opts, credsOpts...), nil
+	}
+
+	ts, err := impersonate.CredentialsTokenSource(ctx, impersonate.CredentialsConfig{
+		TargetPrincipal: o.impersonateServiceAccount,
+		Scopes:          []string{spanner.Scope},
+	}, credsOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to impersonate service account %s: %w", o.impersonateServiceAccount, err)
+	}
+
+	return append(opts, option.WithTokenSource(ts)), nil
+}
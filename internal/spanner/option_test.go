@@ -0,0 +1,77 @@
+package spanner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestOptions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		opts     []Option
+		expected options
+	}{
+		{name: "credentials file", opts: []Option{WithCredentialsFile("c.json")}, expected: options{credentialsFile: "c.json"}},
+		{name: "impersonate service account", opts: []Option{WithImpersonateServiceAccount("sa@p.iam.gserviceaccount.com")}, expected: options{impersonateServiceAccount: "sa@p.iam.gserviceaccount.com"}},
+		{name: "endpoint", opts: []Option{WithEndpoint("spanner.example.com:443")}, expected: options{endpoint: "spanner.example.com:443"}},
+		{name: "emulator host", opts: []Option{WithEmulatorHost("localhost:9010")}, expected: options{emulatorHost: "localhost:9010"}},
+		{name: "database role", opts: []Option{WithDatabaseRole("r")}, expected: options{databaseRole: "r"}},
+		{name: "proto descriptor set", opts: []Option{WithProtoDescriptorSet("descriptors.pb")}, expected: options{protoDescriptorSet: "descriptors.pb"}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			o := new(options)
+			for _, opt := range tt.opts {
+				opt(o)
+			}
+
+			if diff := cmp.Diff(*o, tt.expected, cmp.AllowUnexported(options{})); diff != "" {
+				t.Errorf("\n(-actual, +expected)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestClientOptions(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		opts     options
+		expected int
+	}{
+		{name: "default", opts: options{}, expected: 0},
+		{name: "endpoint and credentials file", opts: options{endpoint: "spanner.example.com:443", credentialsFile: "c.json"}, expected: 2},
+		// The emulator is connected at its host without authentication.
+		{name: "emulator host", opts: options{emulatorHost: "localhost:9010"}, expected: 3},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			clientOpts, err := tt.opts.clientOptions(ctx)
+			if err != nil {
+				t.Fatalf("failed to build client options: %s", err)
+			}
+			if len(clientOpts) != tt.expected {
+				t.Errorf("expected %d client options, but got %d", tt.expected, len(clientOpts))
+			}
+		})
+	}
+
+	o := options{databaseRole: "r"}
+	if role := o.clientConfig().DatabaseRole; role != "r" {
+		t.Errorf("expected the database role r, but got %s", role)
+	}
+}
@@ -0,0 +1,304 @@
+package spanner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kauche/splanter/internal/model"
+)
+
+// sortTablesByForeignKeys moves the tables after their parents and the tables they refer to with foreign keys, keeping the order otherwise.
+// When the foreign keys are in a cycle, the table in the cycle whose foreign keys into the rest of the cycle are all nullable
+// is written first, and the cycle is broken by deferredColumns. Without schemas, the first table in the cycle is written first.
+func sortTablesByForeignKeys(tables []*model.Table, fks []*foreignKey, parents map[string]string, schemas map[string]*tableSchema) {
+	remaining := make(map[string]int)
+	for _, t := range tables {
+		remaining[t.Name]++
+	}
+
+	refers := make(map[string][]string)
+	for child, parent := range parents {
+		refers[child] = append(refers[child], parent)
+	}
+	for _, fk := range fks {
+		if fk.table != fk.referencedTable {
+			refers[fk.table] = append(refers[fk.table], fk.referencedTable)
+		}
+	}
+
+	ready := func(t *model.Table) bool {
+		for _, r := range refers[t.Name] {
+			if remaining[r] > 0 {
+				return false
+			}
+		}
+		return true
+	}
+
+	// onCycle reports whether the table refers to itself through the tables not written yet.
+	onCycle := func(name string) bool {
+		visited := make(map[string]bool)
+		stack := append([]string(nil), refers[name]...)
+		for len(stack) > 0 {
+			r := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if r == name {
+				return true
+			}
+			if visited[r] || remaining[r] == 0 {
+				continue
+			}
+			visited[r] = true
+			stack = append(stack, refers[r]...)
+		}
+		return false
+	}
+
+	// deferrable reports whether the references of the table to the tables not written yet can be written as NULL first.
+	deferrable := func(t *model.Table) bool {
+		if p, ok := parents[t.Name]; ok && remaining[p] > 0 {
+			return false
+		}
+
+		ts := schemas[t.Name]
+		for _, fk := range fks {
+			if fk.table != t.Name || fk.referencedTable == t.Name || remaining[fk.referencedTable] == 0 {
+				continue
+			}
+
+			for _, c := range fk.columns {
+				if cs := ts.columnMap[c]; cs == nil || !cs.nullable {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	pending := append([]*model.Table(nil), tables...)
+	sorted := tables[:0]
+	for len(pending) > 0 {
+		next := -1
+		for i, t := range pending {
+			if ready(t) {
+				next = i
+				break
+			}
+		}
+
+		// No table is ready, so the cycle is broken at a table whose references can be deferred.
+		if next < 0 && schemas != nil {
+			for i, t := range pending {
+				if schemas[t.Name] != nil && onCycle(t.Name) && deferrable(t) {
+					next = i
+					break
+				}
+			}
+		}
+
+		// deferredColumns reports the NOT NULL column if no table in the cycle can be written first.
+		if next < 0 {
+			next = 0
+		}
+
+		t := pending[next]
+		pending = append(pending[:next], pending[next+1:]...)
+		sorted = append(sorted, t)
+		remaining[t.Name]--
+	}
+}
+
+// deferredColumns sorts the records of each table so that rows referred by the self-referencing foreign keys are written first,
+// and returns the foreign key columns of the records which must be written after the referred rows in a cycle are written.
+// Such columns are written as NULL first, and updated after all the records are written.
+func deferredColumns(tables []*model.Table, schemas map[string]*tableSchema, fks []*foreignKey) (map[*model.Record][]string, error) {
+	last := make(map[string]int)
+	for i, t := range tables {
+		last[t.Name] = i
+	}
+
+	deferred := make(map[*model.Record][]string)
+	owners := make(map[*model.Record]*tableSchema)
+	for i, t := range tables {
+		ts := schemas[t.Name]
+		for _, record := range t.Records {
+			owners[record] = ts
+		}
+
+		var self []*foreignKey
+		for _, fk := range fks {
+			if fk.table != t.Name {
+				continue
+			}
+
+			if fk.referencedTable == t.Name {
+				self = append(self, fk)
+				continue
+			}
+
+			// The referred table is written after this table, which means they are in a cycle.
+			if j, ok := last[fk.referencedTable]; ok && j > i {
+				for _, record := range t.Records {
+					if _, ok := foreignKeyValue(ts, record, fk.columns); ok {
+						deferred[record] = append(deferred[record], fk.columns...)
+					}
+				}
+			}
+		}
+
+		if len(self) > 0 {
+			t.Records = orderRecords(ts, t.Records, self, deferred)
+		}
+	}
+
+	for record, columns := range deferred {
+		ts := owners[record]
+		for _, c := range columns {
+			if cs := ts.columnMap[c]; cs != nil && !cs.nullable {
+				return nil, fmt.Errorf("cannot break the cycle of foreign keys of %s: column %s is NOT NULL", ts.name, c)
+			}
+		}
+	}
+
+	return deferred, nil
+}
+
+// orderRecords sorts the records so that the rows referred by the self-referencing foreign keys come first.
+// When the references are in a cycle, the columns of one record in the cycle are added to deferred.
+func orderRecords(ts *tableSchema, records []*model.Record, fks []*foreignKey, deferred map[*model.Record][]string) []*model.Record {
+	// dependencies[i] is the indexes of the records which records[i] refers to, with the foreign key of each.
+	dependencies := make([]map[int]*foreignKey, len(records))
+	for _, fk := range fks {
+		referred := make(map[string]int)
+		for i, record := range records {
+			if key, ok := foreignKeyValue(ts, record, fk.referencedColumns); ok {
+				referred[key] = i
+			}
+		}
+
+		for i, record := range records {
+			key, ok := foreignKeyValue(ts, record, fk.columns)
+			if !ok {
+				continue
+			}
+
+			if j, ok := referred[key]; ok && j != i {
+				if dependencies[i] == nil {
+					dependencies[i] = make(map[int]*foreignKey)
+				}
+				dependencies[i][j] = fk
+			}
+		}
+	}
+
+	placed := make([]bool, len(records))
+	ordered := make([]*model.Record, 0, len(records))
+	for len(ordered) < len(records) {
+		next := -1
+		for i := range records {
+			if placed[i] {
+				continue
+			}
+
+			ready := true
+			for j := range dependencies[i] {
+				if !placed[j] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				next = i
+				break
+			}
+		}
+
+		if next < 0 {
+			next = recordOnCycle(dependencies, placed)
+
+			// No record is ready, so the record in the cycle is written without the references.
+			for j, fk := range dependencies[next] {
+				if !placed[j] {
+					deferred[records[next]] = append(deferred[records[next]], fk.columns...)
+				}
+			}
+		}
+
+		placed[next] = true
+		ordered = append(ordered, records[next])
+	}
+
+	return ordered
+}
+
+// recordOnCycle returns the index of a record in a cycle of the records not placed yet, following the references
+// from the first record not placed, which may only refer to the cycle.
+func recordOnCycle(dependencies []map[int]*foreignKey, placed []bool) int {
+	i := 0
+	for placed[i] {
+		i++
+	}
+
+	visited := make(map[int]bool)
+	for !visited[i] {
+		visited[i] = true
+
+		next := -1
+		for j := range dependencies[i] {
+			if !placed[j] && (next < 0 || j < next) {
+				next = j
+			}
+		}
+		i = next
+	}
+
+	return i
+}
+
+// foreignKeyValue formats the values of the columns of the record, or returns false if any of them is omitted or NULL.
+func foreignKeyValue(ts *tableSchema, record *model.Record, columns []string) (string, bool) {
+	parts := make([]string, len(columns))
+	for i, c := range columns {
+		v, ok := record.Values[c]
+		if !ok || v == nil {
+			return "", false
+		}
+
+		cs, ok := ts.columnMap[c]
+		if !ok {
+			return "", false
+		}
+
+		coerced, err := coerceValue(cs.typ, v)
+		if err != nil {
+			return "", false
+		}
+		parts[i] = formatValue(coerced)
+	}
+
+	return strings.Join(parts, ", "), true
+}
+
+// splitDeferred returns the values to write first, in which the deferred columns are NULL,
+// and the values of the primary key and the deferred columns to update the row with after all the records are written.
+func splitDeferred(ts *tableSchema, values map[string]interface{}, columns []string) (map[string]interface{}, map[string]interface{}) {
+	if len(columns) == 0 {
+		return values, nil
+	}
+
+	first := make(map[string]interface{}, len(values))
+	for c, v := range values {
+		first[c] = v
+	}
+
+	update := make(map[string]interface{}, len(ts.primaryKey)+len(columns))
+	for _, cs := range ts.primaryKey {
+		update[cs.name] = values[cs.name]
+	}
+	for _, c := range columns {
+		first[c] = nil
+		update[c] = values[c]
+	}
+
+	return first, update
+}
@@ -0,0 +1,157 @@
+package spanner
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// readProtoDescriptorSet reads the FileDescriptorSet generated by `protoc --descriptor_set_out --include_imports`,
+// which is the same file as used for `CREATE PROTO BUNDLE`.
+func readProtoDescriptorSet(path string) (*protoregistry.Files, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the proto descriptor set: %w", err)
+	}
+
+	set := new(descriptorpb.FileDescriptorSet)
+	if err := proto.Unmarshal(b, set); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the proto descriptor set: %w", err)
+	}
+
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build the proto descriptors: %w", err)
+	}
+
+	return files, nil
+}
+
+// resolveProtoType looks up the descriptors of the PROTO and ENUM types in the column type.
+// Types not found are left unresolved, so that only encoded values (bytes and numbers) can be used for them.
+func (d *DB) resolveProtoType(typ *columnType) {
+	if typ.elem != nil {
+		d.resolveProtoType(typ.elem)
+		return
+	}
+
+	if typ.protoName == "" {
+		return
+	}
+
+	files := d.protoFiles
+	if files == nil {
+		files = protoregistry.GlobalFiles
+	}
+
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(typ.protoName))
+	if err != nil {
+		return
+	}
+
+	switch desc := desc.(type) {
+	case protoreflect.MessageDescriptor:
+		typ.code = typeCodeProto
+		typ.message = desc
+	case protoreflect.EnumDescriptor:
+		typ.code = typeCodeEnum
+		typ.enum = desc
+	}
+}
+
+// coerceProto converts a yaml mapping, a protojson string or base64 encoded bytes into the serialized message.
+func coerceProto(typ *columnType, v interface{}) ([]byte, error) {
+	var b []byte
+	switch val := v.(type) {
+	case []byte:
+		b = val
+	case string:
+		if !strings.HasPrefix(strings.TrimSpace(val), "{") {
+			decoded, err := base64.StdEncoding.DecodeString(val)
+			if err != nil {
+				return nil, err
+			}
+			b = decoded
+			break
+		}
+		return marshalProtoJSON(typ, []byte(val))
+	case map[string]interface{}:
+		j, err := json.Marshal(val)
+		if err != nil {
+			return nil, err
+		}
+		return marshalProtoJSON(typ, j)
+	default:
+		return nil, fmt.Errorf("unsupported type %T", v)
+	}
+
+	return canonicalProto(typ, b)
+}
+
+func marshalProtoJSON(typ *columnType, j []byte) ([]byte, error) {
+	if typ.message == nil {
+		return nil, fmt.Errorf("the descriptor of %s is not found", typ.protoName)
+	}
+
+	m := dynamicpb.NewMessage(typ.message)
+	if err := protojson.Unmarshal(j, m); err != nil {
+		return nil, err
+	}
+
+	return proto.MarshalOptions{Deterministic: true}.Marshal(m)
+}
+
+// canonicalProto re-serializes the message deterministically so that equal messages are encoded into the same bytes.
+func canonicalProto(typ *columnType, b []byte) ([]byte, error) {
+	if typ.message == nil {
+		return b, nil
+	}
+
+	m := dynamicpb.NewMessage(typ.message)
+	if err := proto.Unmarshal(b, m); err != nil {
+		return nil, err
+	}
+
+	canonical, err := proto.MarshalOptions{Deterministic: true}.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	if canonical == nil {
+		canonical = []byte{}
+	}
+
+	return canonical, nil
+}
+
+// coerceEnum converts the name or the number of an enum value into the number.
+func coerceEnum(typ *columnType, v interface{}) (spanner.NullInt64, error) {
+	s, ok := v.(string)
+	if !ok {
+		return coerceInt64(v)
+	}
+
+	if typ.enum != nil {
+		if ev := typ.enum.Values().ByName(protoreflect.Name(s)); ev != nil {
+			return spanner.NullInt64{Int64: int64(ev.Number()), Valid: true}, nil
+		}
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return spanner.NullInt64{}, fmt.Errorf("unknown enum value %s", s)
+	}
+
+	return spanner.NullInt64{Int64: n, Valid: true}, nil
+}
@@ -0,0 +1,246 @@
+package spanner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+
+	"github.com/kauche/splanter/internal/model"
+)
+
+type tableSchema struct {
+	name       string
+	columns    []*columnSchema
+	columnMap  map[string]*columnSchema
+	primaryKey []*columnSchema
+
+	uniqueIndexes []*uniqueIndex
+}
+
+// uniqueIndex is a unique secondary index, which is checked against the records before writing them.
+type uniqueIndex struct {
+	name    string
+	columns []*columnSchema
+	// nullFiltered is true for NULL_FILTERED indexes, which don't index rows with NULL in the key columns.
+	nullFiltered bool
+}
+
+type columnSchema struct {
+	name                 string
+	typ                  *columnType
+	nullable             bool
+	allowCommitTimestamp bool
+
+	// generated is true for generated columns (`AS (...) STORED`), which cannot be written.
+	generated bool
+	// hasDefault is true for columns with DEFAULT, such as `DEFAULT (GET_NEXT_SEQUENCE_VALUE(SEQUENCE Seq))`.
+	hasDefault bool
+}
+
+func (d *DB) selectTableSchemas(ctx context.Context, tableNames []string) (map[string]*tableSchema, error) {
+	columns, err := d.selectColumns(ctx, tableNames)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get columns: %w", err)
+	}
+
+	keys, err := d.selectPrimaryKeyColumns(ctx, tableNames)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get primary keys: %w", err)
+	}
+
+	commitTimestampColumns, err := d.selectCommitTimestampColumns(ctx, tableNames)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get column options: %w", err)
+	}
+
+	uniqueIndexColumns, err := d.selectUniqueIndexColumns(ctx, tableNames)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get unique indexes: %w", err)
+	}
+
+	schemas := make(map[string]*tableSchema, len(tableNames))
+	for _, name := range tableNames {
+		iscs, ok := columns[name]
+		if !ok {
+			return nil, fmt.Errorf("table %s is not found", name)
+		}
+
+		ts := &tableSchema{
+			name:      name,
+			columns:   make([]*columnSchema, len(iscs)),
+			columnMap: make(map[string]*columnSchema, len(iscs)),
+		}
+
+		for i, isc := range iscs {
+			typ, err := parseColumnType(isc.SpannerType.StringVal)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse the type of %s.%s: %w", name, isc.ColumnName.StringVal, err)
+			}
+			d.resolveProtoType(typ)
+
+			cs := &columnSchema{
+				name:                 isc.ColumnName.StringVal,
+				typ:                  typ,
+				nullable:             isc.IsNullable.StringVal == "YES",
+				allowCommitTimestamp: commitTimestampColumns[name][isc.ColumnName.StringVal],
+				generated:            isc.IsGenerated.StringVal == "ALWAYS",
+				hasDefault:           isc.HasDefault,
+			}
+			ts.columns[i] = cs
+			ts.columnMap[cs.name] = cs
+		}
+
+		for _, k := range keys[name] {
+			cs, ok := ts.columnMap[k]
+			if !ok {
+				return nil, fmt.Errorf("key column %s of %s is not found", k, name)
+			}
+			ts.primaryKey = append(ts.primaryKey, cs)
+		}
+
+		for _, isuic := range uniqueIndexColumns[name] {
+			cs, ok := ts.columnMap[isuic.ColumnName.StringVal]
+			if !ok {
+				return nil, fmt.Errorf("column %s of index %s is not found", isuic.ColumnName.StringVal, isuic.IndexName.StringVal)
+			}
+
+			n := len(ts.uniqueIndexes)
+			if n == 0 || ts.uniqueIndexes[n-1].name != isuic.IndexName.StringVal {
+				ts.uniqueIndexes = append(ts.uniqueIndexes, &uniqueIndex{name: isuic.IndexName.StringVal, nullFiltered: isuic.IsNullFiltered})
+				n++
+			}
+			ts.uniqueIndexes[n-1].columns = append(ts.uniqueIndexes[n-1].columns, cs)
+		}
+
+		schemas[name] = ts
+	}
+
+	return schemas, nil
+}
+
+func (t *tableSchema) columnNames() []string {
+	names := make([]string, len(t.columns))
+	for i, c := range t.columns {
+		names[i] = c.name
+	}
+	return names
+}
+
+// recordKey returns the primary key of the record.
+func (t *tableSchema) recordKey(record *model.Record) (spanner.Key, error) {
+	key := make(spanner.Key, len(t.primaryKey))
+	for i, c := range t.primaryKey {
+		v, ok := record.Values[c.name]
+		if !ok {
+			return nil, fmt.Errorf("record of %s does not have the key column %s", t.name, c.name)
+		}
+
+		coerced, err := coerceValue(c.typ, v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of %s.%s: %w", t.name, c.name, err)
+		}
+		key[i] = coerced
+	}
+
+	return key, nil
+}
+
+// recordValues formats the values of the index columns of the record, or returns false if the record is not checked
+// because a column is omitted, is resolved on save, or is NULL in a NULL_FILTERED index.
+func (i *uniqueIndex) recordValues(record *model.Record) (string, bool) {
+	parts := make([]string, len(i.columns))
+	for j, c := range i.columns {
+		v, ok := record.Values[c.name]
+		if !ok || isCommitTimestamp(v) {
+			return "", false
+		}
+		if _, ok := v.(model.Reference); ok {
+			return "", false
+		}
+
+		coerced, err := coerceValue(c.typ, v)
+		if err != nil {
+			return "", false
+		}
+
+		parts[j] = formatValue(coerced)
+		if parts[j] == "NULL" && i.nullFiltered {
+			return "", false
+		}
+	}
+
+	return "(" + strings.Join(parts, ", ") + ")", true
+}
+
+// generatesKey returns true if the record omits key columns which have DEFAULT, so that the key is generated on insert.
+func (t *tableSchema) generatesKey(record *model.Record) bool {
+	for _, c := range t.primaryKey {
+		if _, ok := record.Values[c.name]; !ok && c.hasDefault {
+			return true
+		}
+	}
+
+	return false
+}
+
+// keyResolvedOnSave returns true if the key of the record is determined only when it is saved,
+// because the key is generated or refers to another record.
+func (t *tableSchema) keyResolvedOnSave(record *model.Record) bool {
+	if t.generatesKey(record) {
+		return true
+	}
+
+	for _, c := range t.primaryKey {
+		if _, ok := record.Values[c.name].(model.Reference); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// rowKey returns the primary key of the row read with all the columns of the table.
+func (t *tableSchema) rowKey(values []interface{}) spanner.Key {
+	key := make(spanner.Key, len(t.primaryKey))
+	for i, c := range t.primaryKey {
+		for j, cs := range t.columns {
+			if cs == c {
+				key[i] = values[j]
+				break
+			}
+		}
+	}
+
+	return key
+}
+
+// formatKey formats the key so that equal keys are formatted into the same string.
+func formatKey(key spanner.Key) string {
+	parts := make([]string, len(key))
+	for i, p := range key {
+		parts[i] = formatValue(p)
+	}
+
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+// readRow decodes a row read with all the columns of the table.
+func (t *tableSchema) readRow(row *spanner.Row) ([]interface{}, error) {
+	values := make([]interface{}, len(t.columns))
+	for i, c := range t.columns {
+		var gcv spanner.GenericColumnValue
+		if err := row.Column(i, &gcv); err != nil {
+			return nil, fmt.Errorf("failed to read column %s: %w", c.name, err)
+		}
+
+		v, err := decodeValue(c.typ, gcv)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode column %s: %w", c.name, err)
+		}
+		values[i] = v
+	}
+
+	return values, nil
+}
@@ -0,0 +1,324 @@
+package spanner
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	spannerpb "google.golang.org/genproto/googleapis/spanner/v1"
+
+	"github.com/kauche/splanter/internal/model"
+)
+
+// needsStatements returns true if some records cannot be written only with mutations,
+// because their keys are generated on insert or they have references to other records.
+func needsStatements(tables []*model.Table, schemas map[string]*tableSchema) bool {
+	for _, table := range tables {
+		for _, record := range table.Records {
+			if record.Name != "" || schemas[table.Name].generatesKey(record) {
+				return true
+			}
+
+			for _, v := range record.Values {
+				if _, ok := v.(model.Reference); ok {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// saveWithStatements writes the records in a read-write transaction, in the order of the tables as far as the references allow.
+// Records generating their keys are inserted with `INSERT ... THEN RETURN` so that the generated values can be referred to
+// by the following records, and the others are written with `INSERT OR UPDATE`.
+// All the records are written with DML since the rows buffered as mutations are invisible to the following statements,
+// which would fail the foreign keys and the interleaving of the records depending on them.
+// Foreign key columns deferred to break cycles are written as NULL first, and updated after all the records are written.
+func (d *DB) saveWithStatements(ctx context.Context, tables []*model.Table, schemas map[string]*tableSchema, deferred map[*model.Record][]string) error {
+	names := make(map[string]bool)
+	for _, table := range tables {
+		for _, record := range table.Records {
+			if record.Name == "" {
+				continue
+			}
+			if names[record.Name] {
+				return recordError(table, record, "", fmt.Errorf("record name %s is duplicated", record.Name))
+			}
+			names[record.Name] = true
+		}
+	}
+
+	for _, table := range tables {
+		for _, record := range table.Records {
+			for c, v := range record.Values {
+				if ref, ok := v.(model.Reference); ok && !names[ref.Name] {
+					return recordError(table, record, c, fmt.Errorf("record %s referred by %s.%s is not found", ref.Name, table.Name, c))
+				}
+			}
+		}
+	}
+
+	var saved map[*model.Record]map[string]interface{}
+	_, err := d.client.ReadWriteTransactionWithOptions(ctx, func(ctx context.Context, tx *spanner.ReadWriteTransaction) error {
+		// The function may be retried, so the records are updated only after the commit.
+		saved = make(map[*model.Record]map[string]interface{})
+		named := make(map[string]map[string]interface{})
+
+		type pendingRecord struct {
+			table  *model.Table
+			record *model.Record
+		}
+
+		var pending []pendingRecord
+		for _, table := range tables {
+			for _, record := range table.Records {
+				pending = append(pending, pendingRecord{table: table, record: record})
+			}
+		}
+
+		// Records are written in passes so that referred records are written before the referring ones
+		// regardless of the order of the files.
+		for len(pending) > 0 {
+			var next []pendingRecord
+			for _, p := range pending {
+				if !referencesResolved(p.record.Values, named) {
+					next = append(next, p)
+					continue
+				}
+
+				values, err := d.saveRecord(ctx, tx, schemas[p.table.Name], p.record, named, deferred[p.record])
+				if err != nil {
+					return recordError(p.table, p.record, "", err)
+				}
+
+				saved[p.record] = values
+				if p.record.Name != "" {
+					named[p.record.Name] = values
+				}
+			}
+
+			if len(next) == len(pending) {
+				_, err := resolveReferences(next[0].record.Values, named)
+				return recordError(next[0].table, next[0].record, "", fmt.Errorf("failed to resolve references of %s: %w", next[0].table.Name, err))
+			}
+			pending = next
+		}
+
+		var updates []*spanner.Mutation
+		for _, table := range tables {
+			ts := schemas[table.Name]
+			for _, record := range table.Records {
+				if _, update := splitDeferred(ts, saved[record], deferred[record]); update != nil {
+					values, err := encodeValues(ts, update)
+					if err != nil {
+						return recordError(table, record, "", err)
+					}
+					updates = append(updates, spanner.UpdateMap(table.Name, values))
+				}
+			}
+		}
+		if err := tx.BufferWrite(updates); err != nil {
+			return fmt.Errorf("failed to buffer mutations: %w", err)
+		}
+
+		return nil
+	}, spanner.TransactionOptions{CommitPriority: spannerpb.RequestOptions_PRIORITY_LOW})
+	if err != nil {
+		return commitError(tables, err)
+	}
+
+	for record, values := range saved {
+		record.Values = values
+	}
+
+	return nil
+}
+
+// saveRecord writes the record whose references are resolved with the deferred columns as NULL, and returns the values of the record.
+func (d *DB) saveRecord(ctx context.Context, tx *spanner.ReadWriteTransaction, ts *tableSchema, record *model.Record, named map[string]map[string]interface{}, deferred []string) (map[string]interface{}, error) {
+	values, err := resolveReferences(record.Values, named)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve references of %s: %w", ts.name, err)
+	}
+	first, _ := splitDeferred(ts, values, deferred)
+
+	if !ts.generatesKey(record) {
+		if err := insertOrUpdate(ctx, tx, ts, first); err != nil {
+			return nil, err
+		}
+		return values, nil
+	}
+
+	returned, err := insertReturning(ctx, tx, ts, first)
+	if err != nil {
+		return nil, err
+	}
+	for c, v := range returned {
+		// The deferred columns keep the values to update the row with.
+		if first[c] == nil && values[c] != nil {
+			continue
+		}
+		values[c] = v
+	}
+
+	return values, nil
+}
+
+// referencesResolved returns true if all the records referred by the values have been written.
+func referencesResolved(values map[string]interface{}, named map[string]map[string]interface{}) bool {
+	for _, v := range values {
+		if ref, ok := v.(model.Reference); ok {
+			if _, ok := named[ref.Name]; !ok {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// resolveReferences returns a copy of the values whose references are replaced with the values of the named records.
+func resolveReferences(values map[string]interface{}, named map[string]map[string]interface{}) (map[string]interface{}, error) {
+	resolved := make(map[string]interface{}, len(values))
+	for c, v := range values {
+		ref, ok := v.(model.Reference)
+		if !ok {
+			resolved[c] = v
+			continue
+		}
+
+		record, ok := named[ref.Name]
+		if !ok {
+			return nil, fmt.Errorf("record %s is in a cycle of references", ref.Name)
+		}
+
+		rv, ok := record[ref.Column]
+		if !ok {
+			return nil, fmt.Errorf("record %s does not have column %s", ref.Name, ref.Column)
+		}
+		resolved[c] = rv
+	}
+
+	return resolved, nil
+}
+
+// insertOrUpdate inserts a row with DML, or updates the columns of the row if it exists.
+func insertOrUpdate(ctx context.Context, tx *spanner.ReadWriteTransaction, ts *tableSchema, values map[string]interface{}) error {
+	columns, exprs, params, err := insertValues(ts, values)
+	if err != nil {
+		return err
+	}
+
+	statement := spanner.Statement{
+		SQL:    fmt.Sprintf("INSERT OR UPDATE INTO `%s` (%s) VALUES (%s)", ts.name, strings.Join(columns, ", "), strings.Join(exprs, ", ")),
+		Params: params,
+	}
+
+	if _, err := tx.Update(ctx, statement); err != nil {
+		return fmt.Errorf("failed to write a record of %s: %w", ts.name, err)
+	}
+
+	return nil
+}
+
+// insertReturning inserts a row with DML and returns the values of the writable columns of the inserted row,
+// including the generated key.
+func insertReturning(ctx context.Context, tx *spanner.ReadWriteTransaction, ts *tableSchema, values map[string]interface{}) (map[string]interface{}, error) {
+	quoted, exprs, params, err := insertValues(ts, values)
+	if err != nil {
+		return nil, err
+	}
+
+	// The pending commit timestamp cannot be read in the transaction.
+	var returning []string
+	for _, c := range ts.columns {
+		if v, ok := values[c.name]; !c.generated && !(ok && isCommitTimestamp(v)) {
+			returning = append(returning, "`"+c.name+"`")
+		}
+	}
+
+	statement := spanner.Statement{
+		SQL:    fmt.Sprintf("INSERT INTO `%s` (%s) VALUES (%s) THEN RETURN %s", ts.name, strings.Join(quoted, ", "), strings.Join(exprs, ", "), strings.Join(returning, ", ")),
+		Params: params,
+	}
+
+	returned := make(map[string]interface{}, len(returning))
+	err = tx.Query(ctx, statement).Do(func(row *spanner.Row) error {
+		for i, name := range row.ColumnNames() {
+			var gcv spanner.GenericColumnValue
+			if err := row.Column(i, &gcv); err != nil {
+				return fmt.Errorf("failed to read column %s: %w", name, err)
+			}
+
+			v, err := decodeValue(ts.columnMap[name].typ, gcv)
+			if err != nil {
+				return fmt.Errorf("failed to decode column %s: %w", name, err)
+			}
+			returned[name] = v
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert a record of %s: %w", ts.name, err)
+	}
+
+	return returned, nil
+}
+
+// insertValues returns the quoted columns, the expressions and the parameters to insert the values with DML.
+func insertValues(ts *tableSchema, values map[string]interface{}) ([]string, []string, map[string]interface{}, error) {
+	columns := make([]string, 0, len(values))
+	for c := range values {
+		columns = append(columns, c)
+	}
+	sort.Strings(columns)
+
+	params := make(map[string]interface{}, len(columns))
+	quoted := make([]string, len(columns))
+	exprs := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = "`" + c + "`"
+
+		if isCommitTimestamp(values[c]) {
+			exprs[i] = "PENDING_COMMIT_TIMESTAMP()"
+			continue
+		}
+
+		v, err := coerceValue(ts.columnMap[c].typ, values[c])
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("invalid value of %s.%s: %w", ts.name, c, err)
+		}
+
+		param := fmt.Sprintf("p%d", i)
+		params[param] = v
+		exprs[i] = "@" + param
+
+		// Parameters of the types not supported by the client library are sent as the encoded types.
+		if typ := ts.columnMap[c].typ; needsEncoding(typ) {
+			exprs[i] = fmt.Sprintf("CAST(@%s AS %s)", param, castType(typ))
+		}
+	}
+
+	return quoted, exprs, params, nil
+}
+
+// castType returns the type name to cast the encoded parameters into.
+func castType(typ *columnType) string {
+	switch typ.code {
+	case spannerpb.TypeCode_ARRAY:
+		return "ARRAY<" + castType(typ.elem) + ">"
+	case typeCodeProto, typeCodeEnum:
+		return "`" + typ.protoName + "`"
+	case typeCodeFloat32:
+		return "FLOAT32"
+	case typeCodeInterval:
+		return "INTERVAL"
+	default:
+		return typ.raw
+	}
+}
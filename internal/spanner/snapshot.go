@@ -0,0 +1,469 @@
+package spanner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"cloud.google.com/go/spanner"
+	spannerpb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/kauche/splanter/internal/model"
+)
+
+// Snapshot is the contents of tables captured at a point in time.
+type Snapshot struct {
+	Tables []*SnapshotTable
+}
+
+type SnapshotTable struct {
+	Name    string
+	Columns []*SnapshotColumn
+	Rows    [][]*structpb.Value
+
+	// Keys limits the captured rows to the keys. nil means all the rows of the table.
+	Keys []spanner.Key
+}
+
+type SnapshotColumn struct {
+	Name string
+	Type *spannerpb.Type
+}
+
+type snapshotFile struct {
+	Tables []*snapshotFileTable `json:"tables"`
+}
+
+type snapshotFileTable struct {
+	Name    string                `json:"name"`
+	Columns []*snapshotFileColumn `json:"columns"`
+	Rows    []json.RawMessage     `json:"rows"`
+}
+
+type snapshotFileColumn struct {
+	Name string          `json:"name"`
+	Type json.RawMessage `json:"type"`
+}
+
+// ReadSnapshot reads the snapshot written by Snapshot.Write.
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	var f snapshotFile
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+
+	snapshot := &Snapshot{
+		Tables: make([]*SnapshotTable, len(f.Tables)),
+	}
+	for i, ft := range f.Tables {
+		table := &SnapshotTable{
+			Name:    ft.Name,
+			Columns: make([]*SnapshotColumn, len(ft.Columns)),
+			Rows:    make([][]*structpb.Value, len(ft.Rows)),
+		}
+
+		for j, fc := range ft.Columns {
+			typ := new(spannerpb.Type)
+			if err := protojson.Unmarshal(fc.Type, typ); err != nil {
+				return nil, fmt.Errorf("failed to decode the type of %s.%s: %w", ft.Name, fc.Name, err)
+			}
+			table.Columns[j] = &SnapshotColumn{Name: fc.Name, Type: typ}
+		}
+
+		for j, fr := range ft.Rows {
+			row := new(structpb.ListValue)
+			if err := protojson.Unmarshal(fr, row); err != nil {
+				return nil, fmt.Errorf("failed to decode a row of %s: %w", ft.Name, err)
+			}
+			if len(row.Values) != len(table.Columns) {
+				return nil, fmt.Errorf("a row of %s has %d values but the table has %d columns", ft.Name, len(row.Values), len(table.Columns))
+			}
+			table.Rows[j] = row.Values
+		}
+
+		snapshot.Tables[i] = table
+	}
+
+	return snapshot, nil
+}
+
+// Write writes the snapshot as JSON.
+func (s *Snapshot) Write(w io.Writer) error {
+	f := snapshotFile{
+		Tables: make([]*snapshotFileTable, len(s.Tables)),
+	}
+	for i, table := range s.Tables {
+		if table.Keys != nil {
+			return fmt.Errorf("snapshot of %s captures only the rows of specific keys and cannot be written", table.Name)
+		}
+
+		ft := &snapshotFileTable{
+			Name:    table.Name,
+			Columns: make([]*snapshotFileColumn, len(table.Columns)),
+			Rows:    make([]json.RawMessage, len(table.Rows)),
+		}
+
+		for j, column := range table.Columns {
+			typ, err := protojson.Marshal(column.Type)
+			if err != nil {
+				return fmt.Errorf("failed to encode the type of %s.%s: %w", table.Name, column.Name, err)
+			}
+			ft.Columns[j] = &snapshotFileColumn{Name: column.Name, Type: typ}
+		}
+
+		for j, row := range table.Rows {
+			r, err := protojson.Marshal(&structpb.ListValue{Values: row})
+			if err != nil {
+				return fmt.Errorf("failed to encode a row of %s: %w", table.Name, err)
+			}
+			ft.Rows[j] = r
+		}
+
+		f.Tables[i] = ft
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(f); err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	return nil
+}
+
+// Snapshot captures the current rows of the given tables.
+func (d *DB) Snapshot(ctx context.Context, tableNames []string) (*Snapshot, error) {
+	return d.snapshot(ctx, tableNames, nil)
+}
+
+// SnapshotRecords captures the current rows having the same primary keys as the records.
+// Restoring the snapshot reverts only these rows, so that it undoes Save of the records.
+func (d *DB) SnapshotRecords(ctx context.Context, tables []*model.Table) (*Snapshot, error) {
+	var tableNames []string
+	for _, t := range tables {
+		tableNames = append(tableNames, t.Name)
+	}
+
+	schemas, err := d.selectTableSchemas(ctx, tableNames)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get table schemas: %w", err)
+	}
+
+	// The key columns filled by the rules are needed to find the rows which Save is going to write.
+	applyKeyRules(tables, schemas)
+
+	names := make([]string, 0, len(tables))
+	keys := make(map[string][]spanner.Key, len(tables))
+	for _, t := range tables {
+		if _, ok := keys[t.Name]; !ok {
+			names = append(names, t.Name)
+			keys[t.Name] = []spanner.Key{}
+		}
+
+		for _, record := range t.Records {
+			// The keys of these records are unknown until Save, and they are added by AddRecordKeys.
+			if schemas[t.Name].keyResolvedOnSave(record) {
+				continue
+			}
+
+			key, err := schemas[t.Name].recordKey(record)
+			if err != nil {
+				return nil, err
+			}
+			keys[t.Name] = append(keys[t.Name], key)
+		}
+	}
+
+	return d.snapshot(ctx, names, keys)
+}
+
+// AddRecordKeys adds the keys generated by Save to the snapshot captured by SnapshotRecords,
+// so that restoring the snapshot deletes the rows inserted with the generated keys.
+func (d *DB) AddRecordKeys(ctx context.Context, snapshot *Snapshot, tables []*model.Table) error {
+	var tableNames []string
+	for _, t := range tables {
+		tableNames = append(tableNames, t.Name)
+	}
+
+	schemas, err := d.selectTableSchemas(ctx, tableNames)
+	if err != nil {
+		return fmt.Errorf("failed to get table schemas: %w", err)
+	}
+
+	snapshotTables := make(map[string]*SnapshotTable, len(snapshot.Tables))
+	for _, st := range snapshot.Tables {
+		snapshotTables[st.Name] = st
+	}
+
+	for _, t := range tables {
+		st, ok := snapshotTables[t.Name]
+		if !ok || st.Keys == nil {
+			continue
+		}
+
+		captured := make(map[string]bool, len(st.Keys))
+		for _, k := range st.Keys {
+			captured[formatKey(k)] = true
+		}
+
+		for _, record := range t.Records {
+			key, err := schemas[t.Name].recordKey(record)
+			if err != nil {
+				return err
+			}
+
+			if !captured[formatKey(key)] {
+				captured[formatKey(key)] = true
+				st.Keys = append(st.Keys, key)
+			}
+		}
+	}
+
+	return nil
+}
+
+// snapshot captures the rows of the given tables. Only the rows of keys are captured for the tables in keys.
+func (d *DB) snapshot(ctx context.Context, tableNames []string, keys map[string][]spanner.Key) (*Snapshot, error) {
+	columns, err := d.selectColumns(ctx, tableNames)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get columns: %w", err)
+	}
+
+	tx := d.client.ReadOnlyTransaction()
+	defer tx.Close()
+
+	snapshot := &Snapshot{
+		Tables: make([]*SnapshotTable, len(tableNames)),
+	}
+	for i, name := range tableNames {
+		iscs, ok := columns[name]
+		if !ok {
+			return nil, fmt.Errorf("table %s is not found", name)
+		}
+
+		// Generated columns are not captured since they cannot be written on restore.
+		var columnNames []string
+		for _, isc := range iscs {
+			if isc.IsGenerated.StringVal != "ALWAYS" {
+				columnNames = append(columnNames, isc.ColumnName.StringVal)
+			}
+		}
+
+		table := &SnapshotTable{
+			Name: name,
+			Keys: keys[name],
+		}
+
+		iter := tx.Read(ctx, name, table.keySet(), columnNames)
+		err := iter.Do(func(row *spanner.Row) error {
+			values := make([]*structpb.Value, row.Size())
+			for j := range values {
+				var v spanner.GenericColumnValue
+				if err := row.Column(j, &v); err != nil {
+					return fmt.Errorf("failed to read column %s: %w", columnNames[j], err)
+				}
+				values[j] = v.Value
+			}
+			table.Rows = append(table.Rows, values)
+
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+
+		fields := iter.Metadata.GetRowType().GetFields()
+		table.Columns = make([]*SnapshotColumn, len(columnNames))
+		for j, c := range columnNames {
+			table.Columns[j] = &SnapshotColumn{Name: c}
+			if j < len(fields) {
+				table.Columns[j].Type = fields[j].GetType()
+			}
+		}
+
+		snapshot.Tables[i] = table
+	}
+
+	return snapshot, nil
+}
+
+func (t *SnapshotTable) keySet() spanner.KeySet {
+	if t.Keys == nil {
+		return spanner.AllKeys()
+	}
+
+	return spanner.KeySetFromKeys(t.Keys...)
+}
+
+// Restore reverts the tables in the snapshot to the captured rows.
+// Rows added since the snapshot are deleted, deleted rows are re-inserted and changed rows are reverted.
+// For the tables captured only for specific keys, rows of the other keys are left as they are.
+func (d *DB) Restore(ctx context.Context, snapshot *Snapshot) error {
+	tables := make([]*model.Table, len(snapshot.Tables))
+	snapshotTables := make(map[string]*SnapshotTable, len(snapshot.Tables))
+	tableNames := make([]string, len(snapshot.Tables))
+	for i, st := range snapshot.Tables {
+		tables[i] = &model.Table{Name: st.Name}
+		snapshotTables[st.Name] = st
+		tableNames[i] = st.Name
+	}
+
+	if _, err := d.sortTablesByDependencies(ctx, tables, nil); err != nil {
+		return fmt.Errorf("failed to sort tables: %w", err)
+	}
+
+	keys, err := d.selectPrimaryKeyColumns(ctx, tableNames)
+	if err != nil {
+		return fmt.Errorf("failed to get primary keys: %w", err)
+	}
+
+	_, err = d.client.ReadWriteTransaction(ctx, func(ctx context.Context, tx *spanner.ReadWriteTransaction) error {
+		deletes := make([][]*spanner.Mutation, len(tables))
+		var upserts []*spanner.Mutation
+
+		for i, t := range tables {
+			st := snapshotTables[t.Name]
+
+			keyIndexes, err := snapshotKeyIndexes(st, keys[st.Name])
+			if err != nil {
+				return err
+			}
+
+			columnNames := make([]string, len(st.Columns))
+			for j, c := range st.Columns {
+				columnNames[j] = c.Name
+			}
+
+			rows := make(map[string][]*structpb.Value, len(st.Rows))
+			for _, row := range st.Rows {
+				key, err := snapshotRowKey(row, keyIndexes)
+				if err != nil {
+					return fmt.Errorf("failed to encode a key of %s: %w", st.Name, err)
+				}
+				rows[key] = row
+			}
+
+			unchanged := make(map[string]bool)
+			err = tx.Read(ctx, st.Name, st.keySet(), columnNames).Do(func(row *spanner.Row) error {
+				values := make([]spanner.GenericColumnValue, row.Size())
+				for j := range values {
+					if err := row.Column(j, &values[j]); err != nil {
+						return fmt.Errorf("failed to read column %s: %w", columnNames[j], err)
+					}
+				}
+
+				currentRow := make([]*structpb.Value, len(values))
+				for j, v := range values {
+					currentRow[j] = v.Value
+				}
+
+				key, err := snapshotRowKey(currentRow, keyIndexes)
+				if err != nil {
+					return fmt.Errorf("failed to encode a key of %s: %w", st.Name, err)
+				}
+
+				snapshotRow, ok := rows[key]
+				if !ok {
+					k := make(spanner.Key, len(keyIndexes))
+					for j, idx := range keyIndexes {
+						if k[j], err = keyPart(values[idx]); err != nil {
+							return err
+						}
+					}
+					deletes[i] = append(deletes[i], spanner.Delete(st.Name, k))
+					return nil
+				}
+
+				if snapshotRowEqual(snapshotRow, currentRow) {
+					unchanged[key] = true
+				}
+
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", st.Name, err)
+			}
+
+			for _, row := range st.Rows {
+				key, _ := snapshotRowKey(row, keyIndexes)
+				if unchanged[key] {
+					continue
+				}
+
+				values := make([]interface{}, len(row))
+				for j, v := range row {
+					values[j] = spanner.GenericColumnValue{Type: st.Columns[j].Type, Value: v}
+				}
+				upserts = append(upserts, spanner.InsertOrUpdate(st.Name, columnNames, values))
+			}
+		}
+
+		// Delete rows from children to parents, then write rows from parents to children.
+		var mutations []*spanner.Mutation
+		for i := len(deletes) - 1; i >= 0; i-- {
+			mutations = append(mutations, deletes[i]...)
+		}
+		mutations = append(mutations, upserts...)
+
+		return tx.BufferWrite(mutations)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to restore tables: %w", err)
+	}
+
+	return nil
+}
+
+func snapshotKeyIndexes(table *SnapshotTable, keyColumns []string) ([]int, error) {
+	if len(keyColumns) == 0 {
+		return nil, fmt.Errorf("primary key of %s is not found", table.Name)
+	}
+
+	indexes := make([]int, len(keyColumns))
+	for i, kc := range keyColumns {
+		indexes[i] = -1
+		for j, c := range table.Columns {
+			if c.Name == kc {
+				indexes[i] = j
+				break
+			}
+		}
+		if indexes[i] < 0 {
+			return nil, fmt.Errorf("snapshot of %s does not contain the key column %s", table.Name, kc)
+		}
+	}
+
+	return indexes, nil
+}
+
+func snapshotRowKey(row []*structpb.Value, keyIndexes []int) (string, error) {
+	key := &structpb.ListValue{Values: make([]*structpb.Value, len(keyIndexes))}
+	for i, idx := range keyIndexes {
+		key.Values[i] = row[idx]
+	}
+
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(key)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+func snapshotRowEqual(x, y []*structpb.Value) bool {
+	if len(x) != len(y) {
+		return false
+	}
+
+	for i := range x {
+		if !proto.Equal(x[i], y[i]) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -2,51 +2,446 @@ package spanner
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"path"
 	"sort"
+	"strings"
 
 	"cloud.google.com/go/spanner"
 	spannerpb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/protobuf/reflect/protoregistry"
 
 	"github.com/kauche/splanter/internal/model"
 )
 
 type DB struct {
 	client *spanner.Client
+
+	// protoFiles is the descriptors of PROTO and ENUM columns. nil means protoregistry.GlobalFiles.
+	protoFiles *protoregistry.Files
+
+	// ownsClient is true if the client is created by NewDB, which is closed by Close.
+	ownsClient bool
 }
 
-func NewDB(ctx context.Context, project, instance, database string) (*DB, error) {
-	client, err := spanner.NewClient(ctx, fmt.Sprintf("projects/%s/instances/%s/databases/%s", project, instance, database))
+func NewDB(ctx context.Context, project, instance, database string, opts ...Option) (*DB, error) {
+	o := new(options)
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	clientOpts, err := o.clientOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	protoFiles, err := o.protoFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := spanner.NewClientWithConfig(ctx, fmt.Sprintf("projects/%s/instances/%s/databases/%s", project, instance, database), o.clientConfig(), clientOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create spanner client: %w", err)
 	}
-	return &DB{client: client}, nil
+	return &DB{client: client, protoFiles: protoFiles, ownsClient: true}, nil
 }
 
+// NewDBWithClient returns a DB which uses the existing client. The client is owned by the caller and is not closed by Close.
+func NewDBWithClient(client *spanner.Client) *DB {
+	return &DB{client: client}
+}
+
+// Close closes the client created by NewDB. It does nothing for the client given to NewDBWithClient.
 func (d *DB) Close() {
-	d.client.Close()
+	if d.ownsClient {
+		d.client.Close()
+	}
 }
 
+// Save writes the records with InsertOrUpdate, so that columns omitted in a record are filled with their DEFAULT
+// (or NULL) for a new row and are left as they are for an existing row. They are never nulled out like Replace does.
+//
+// Columns omitted in the records are filled by the rules of the tables first. Records of the same primary key in the tables
+// with OverrideDuplicates are merged into the first one, and the others are removed from table.Records.
+// Records which omit key columns with DEFAULT, such as keys generated by sequences, are inserted with DML instead.
+// The generated values and the resolved references are written back to the records.
 func (d *DB) Save(ctx context.Context, tables []*model.Table) error {
-	if err := d.sortTablesByDependencies(ctx, tables); err != nil {
+	tableNames := make([]string, len(tables))
+	for i, t := range tables {
+		tableNames[i] = t.Name
+	}
+
+	schemas, err := d.selectTableSchemas(ctx, tableNames)
+	if err != nil {
+		return fmt.Errorf("failed to get table schemas: %w", err)
+	}
+
+	deps, err := d.sortTablesByDependencies(ctx, tables, schemas)
+	if err != nil {
 		return fmt.Errorf("failed to sort tables: %w", err)
 	}
 
+	// The keys filled by the rules are needed to find duplicates, while the other columns are filled after merging them
+	// so that the rules don't override the columns of the earlier records.
+	applyKeyRules(tables, schemas)
+
+	if err := mergeDuplicateRecords(tables, schemas); err != nil {
+		return fmt.Errorf("invalid records: %w", err)
+	}
+
+	applyColumnRules(tables, schemas)
+	applyCommitTimestamps(tables, schemas)
+
+	if err := validateRecords(tables, schemas); err != nil {
+		return fmt.Errorf("invalid records: %w", err)
+	}
+
+	if err := checkUniqueIndexes(tables, schemas); err != nil {
+		return fmt.Errorf("invalid records: %w", err)
+	}
+
+	if err := d.checkReferences(ctx, tables, schemas, deps); err != nil {
+		return fmt.Errorf("invalid records: %w", err)
+	}
+
+	if needsStatements(tables, schemas) {
+		deferred, err := deferredColumns(tables, schemas, deps.fks)
+		if err != nil {
+			return fmt.Errorf("failed to order records: %w", err)
+		}
+
+		return d.saveWithStatements(ctx, tables, schemas, deferred)
+	}
+
+	// Foreign keys are checked on commit for mutations, so the cycles of them need not be broken.
 	var mutations []*spanner.Mutation
 	for _, table := range tables {
-		for _, records := range table.Records {
-			mutations = append(mutations, spanner.InsertOrUpdateMap(table.Name, records.Values))
+		ts := schemas[table.Name]
+		for _, record := range table.Records {
+			values, err := encodeValues(ts, record.Values)
+			if err != nil {
+				return recordError(table, record, "", err)
+			}
+			mutations = append(mutations, spanner.InsertOrUpdateMap(table.Name, values))
 		}
 	}
 
 	if _, err := d.client.Apply(ctx, mutations, spanner.Priority(spannerpb.RequestOptions_PRIORITY_LOW)); err != nil {
-		return fmt.Errorf("failed to insert records: %w", err)
+		return commitError(tables, err)
+	}
+
+	return nil
+}
+
+// commitError adds the files of the records to the error of the commit, which Spanner reports for the whole commit
+// rather than for a record, unless the error is located at a record already.
+func commitError(tables []*model.Table, err error) error {
+	var pe *model.PositionError
+	if files := recordFiles(tables); len(files) > 0 && !errors.As(err, &pe) {
+		return fmt.Errorf("failed to insert records of %s: %w", strings.Join(files, ", "), err)
+	}
+
+	return fmt.Errorf("failed to insert records: %w", err)
+}
+
+// recordFiles returns the files which the records are loaded from, in the order of the records.
+func recordFiles(tables []*model.Table) []string {
+	var files []string
+	seen := make(map[string]bool)
+	for _, table := range tables {
+		for _, record := range table.Records {
+			if f := record.Position.File; f != "" && !seen[f] {
+				seen[f] = true
+				files = append(files, f)
+			}
+		}
+	}
+
+	return files
+}
+
+// encodeValues returns a copy of the values in which files and the values of the column types not supported by the client library,
+// such as yaml mappings of PROTO columns and names of ENUM columns, are converted into the forms mutations can take.
+func encodeValues(ts *tableSchema, values map[string]interface{}) (map[string]interface{}, error) {
+	encoded := make(map[string]interface{}, len(values))
+	for c, v := range values {
+		cs := ts.columnMap[c]
+		if _, ok := v.(model.File); !ok && !needsEncoding(cs.typ) {
+			encoded[c] = v
+			continue
+		}
+
+		ev, err := coerceValue(cs.typ, v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of %s.%s: %w", ts.name, c, err)
+		}
+		encoded[c] = ev
+	}
+
+	return encoded, nil
+}
+
+// recordError returns the error at the position of the column of the record, or of the record if the column is empty,
+// so that the error is printed with the snippet of the yaml file. Records not loaded from files have no position.
+func recordError(table *model.Table, record *model.Record, column string, err error) error {
+	pos, ok := record.Positions[column]
+	if !ok {
+		pos = record.Position
+	}
+
+	if pos.File == "" {
+		return err
+	}
+
+	return &model.PositionError{Position: pos, Source: table.Source, Err: err}
+}
+
+// hasLimits returns true if the values of the type are limited by the declared length or vector_length.
+func hasLimits(typ *columnType) bool {
+	if typ.elem != nil {
+		return typ.vectorLength > 0 || hasLimits(typ.elem)
+	}
+
+	return typ.length > 0
+}
+
+// checkUniqueIndexes fails if records have the same values of the key columns of a unique index,
+// which Spanner reports only as an error of the whole commit. NOTE: The rows in the database are not checked.
+func checkUniqueIndexes(tables []*model.Table, schemas map[string]*tableSchema) error {
+	seen := make(map[string]map[string]*model.Record)
+	for _, table := range tables {
+		for _, index := range schemas[table.Name].uniqueIndexes {
+			if seen[index.name] == nil {
+				seen[index.name] = make(map[string]*model.Record)
+			}
+
+			for _, record := range table.Records {
+				values, ok := index.recordValues(record)
+				if !ok {
+					continue
+				}
+
+				if first, ok := seen[index.name][values]; ok {
+					return recordError(table, record, "", fmt.Errorf("duplicate values %s of unique index %s of %s, which are also at %s", values, index.name, table.Name, first.Position))
+				}
+				seen[index.name][values] = record
+			}
+		}
 	}
 
 	return nil
 }
 
-func (d *DB) sortTablesByDependencies(ctx context.Context, tables []*model.Table) error {
+// mergeDuplicateRecords fails if records of a table have the same primary key, within a file or across files,
+// since InsertOrUpdate would silently let one of them win. For the tables with OverrideDuplicates,
+// the later record overrides the columns of the earlier one instead, and the later record is removed from table.Records
+// of the caller, so that the records left are the rows actually written.
+func mergeDuplicateRecords(tables []*model.Table, schemas map[string]*tableSchema) error {
+	seen := make(map[string]map[string]*model.Record)
+	for _, table := range tables {
+		ts := schemas[table.Name]
+		if seen[table.Name] == nil {
+			seen[table.Name] = make(map[string]*model.Record)
+		}
+
+		records := table.Records[:0]
+		for _, record := range table.Records {
+			// Records whose keys are generated or referred are not known until they are saved.
+			if ts.keyResolvedOnSave(record) {
+				records = append(records, record)
+				continue
+			}
+
+			key, err := ts.recordKey(record)
+			if err != nil {
+				return recordError(table, record, "", err)
+			}
+
+			k := formatKey(key)
+			first, ok := seen[table.Name][k]
+			if !ok {
+				seen[table.Name][k] = record
+				records = append(records, record)
+				continue
+			}
+
+			if !table.OverrideDuplicates {
+				return recordError(table, record, "", fmt.Errorf("duplicate primary key %s of %s, which is also at %s", k, table.Name, first.Position))
+			}
+
+			for c, v := range record.Values {
+				first.Values[c] = v
+			}
+			if record.Name != "" {
+				first.Name = record.Name
+			}
+		}
+		table.Records = records
+	}
+
+	return nil
+}
+
+// applyColumnRules fills the columns matching the rules of the tables, in the records which omit them.
+// The first matching rule takes precedence.
+func applyColumnRules(tables []*model.Table, schemas map[string]*tableSchema) {
+	applyRules(tables, schemas, false)
+}
+
+// applyKeyRules fills only the primary key columns matching the rules, so that the keys of the records are known.
+func applyKeyRules(tables []*model.Table, schemas map[string]*tableSchema) {
+	applyRules(tables, schemas, true)
+}
+
+func applyRules(tables []*model.Table, schemas map[string]*tableSchema, keysOnly bool) {
+	for _, table := range tables {
+		columns := schemas[table.Name].columns
+		if keysOnly {
+			columns = schemas[table.Name].primaryKey
+		}
+
+		for _, c := range columns {
+			rule := columnRule(table, c)
+			if rule == nil {
+				continue
+			}
+
+			for _, record := range table.Records {
+				if _, ok := record.Values[c.name]; !ok {
+					record.Values[c.name] = rule.Value
+				}
+			}
+		}
+	}
+}
+
+// applyCommitTimestamps replaces the strings `PENDING_COMMIT_TIMESTAMP()` in TIMESTAMP columns with the placeholder of
+// the commit timestamp. The strings in the other columns are written as they are.
+func applyCommitTimestamps(tables []*model.Table, schemas map[string]*tableSchema) {
+	for _, table := range tables {
+		for _, c := range schemas[table.Name].columns {
+			if c.typ.code != spannerpb.TypeCode_TIMESTAMP {
+				continue
+			}
+
+			for _, record := range table.Records {
+				if record.Values[c.name] == pendingCommitTimestamp {
+					record.Values[c.name] = spanner.CommitTimestamp
+				}
+			}
+		}
+	}
+}
+
+// columnRule returns the first rule of the table which matches the column, or nil if none matches.
+func columnRule(table *model.Table, c *columnSchema) *model.ColumnRule {
+	if c.generated {
+		return nil
+	}
+
+	for _, rule := range table.Rules {
+		if matched, _ := path.Match(rule.Table, table.Name); rule.Table != "" && !matched {
+			continue
+		}
+		if matched, _ := path.Match(rule.Column, c.name); matched {
+			return rule
+		}
+	}
+
+	return nil
+}
+
+// validateRecords checks the records against the table schemas before writing them.
+func validateRecords(tables []*model.Table, schemas map[string]*tableSchema) error {
+	for _, table := range tables {
+		ts := schemas[table.Name]
+		for _, record := range table.Records {
+			for column, v := range record.Values {
+				cs, ok := ts.columnMap[column]
+				if !ok {
+					return recordError(table, record, column, fmt.Errorf("column %s is not found in %s", column, table.Name))
+				}
+
+				if cs.generated {
+					return recordError(table, record, column, fmt.Errorf("column %s.%s is a generated column and cannot be written", table.Name, column))
+				}
+
+				if isCommitTimestamp(v) && !cs.allowCommitTimestamp {
+					return recordError(table, record, column, fmt.Errorf("column %s.%s does not allow the commit timestamp: allow_commit_timestamp=true is required", table.Name, column))
+				}
+
+				if hasLimits(cs.typ) {
+					if _, err := coerceValue(cs.typ, v); err != nil {
+						return recordError(table, record, column, fmt.Errorf("invalid value of %s.%s: %w", table.Name, column, err))
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Delete deletes the rows having the same primary keys as the records, from children to parents.
+// The key columns omitted in the records are filled by the rules as Save does. Records whose keys are unknown until saved,
+// such as generated keys and references, are not deleted and returned as skipped.
+func (d *DB) Delete(ctx context.Context, tables []*model.Table) ([]*SkippedRecord, error) {
+	if _, err := d.sortTablesByDependencies(ctx, tables, nil); err != nil {
+		return nil, fmt.Errorf("failed to sort tables: %w", err)
+	}
+
+	tableNames := make([]string, len(tables))
+	for i, t := range tables {
+		tableNames[i] = t.Name
+	}
+
+	schemas, err := d.selectTableSchemas(ctx, tableNames)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get table schemas: %w", err)
+	}
+
+	applyKeyRules(tables, schemas)
+
+	var (
+		mutations []*spanner.Mutation
+		skipped   []*SkippedRecord
+	)
+	for i := len(tables) - 1; i >= 0; i-- {
+		table := tables[i]
+		ts := schemas[table.Name]
+		for _, record := range table.Records {
+			if ts.keyResolvedOnSave(record) {
+				skipped = append(skipped, &SkippedRecord{Position: record.Position, Reason: "the key is resolved on save"})
+				continue
+			}
+
+			key, err := ts.recordKey(record)
+			if err != nil {
+				return nil, recordError(table, record, "", err)
+			}
+			mutations = append(mutations, spanner.Delete(table.Name, key))
+		}
+	}
+
+	if _, err := d.client.Apply(ctx, mutations, spanner.Priority(spannerpb.RequestOptions_PRIORITY_LOW)); err != nil {
+		return nil, fmt.Errorf("failed to delete records: %w", err)
+	}
+
+	return skipped, nil
+}
+
+// dependencies is the parent table of each interleaved table and the foreign keys of the tables.
+type dependencies struct {
+	parents map[string]string
+	fks     []*foreignKey
+}
+
+// sortTablesByDependencies sorts the tables so that parents and the tables referred by foreign keys come first,
+// and returns the dependencies used to sort them.
+// The schemas are used to break the cycles of foreign keys at nullable columns, which may be nil if the cycles are not broken.
+func (d *DB) sortTablesByDependencies(ctx context.Context, tables []*model.Table, schemas map[string]*tableSchema) (*dependencies, error) {
 	tableNames := make([]string, len(tables))
 	for i, t := range tables {
 		tableNames[i] = t.Name
@@ -55,7 +450,7 @@ func (d *DB) sortTablesByDependencies(ctx context.Context, tables []*model.Table
 	statement := spanner.Statement{
 		// NOTE: `WHERE TABLE_TYPE = "BASE TABLE"` is enough to select user tables, but spanner-emulator doesn't support it for now.
 		// SQL: `SELECT TABLE_NAME, PARENT_TABLE_NAME FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_TYPE = "BASE TABLE"`,
-		SQL: `SELECT TABLE_NAME, PARENT_TABLE_NAME FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_NAME IN UNNEST (@tables)`,
+		SQL: `SELECT TABLE_NAME, PARENT_TABLE_NAME FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA = "" AND TABLE_NAME IN UNNEST (@tables)`,
 		Params: map[string]interface{}{
 			"tables": tableNames,
 		},
@@ -73,7 +468,7 @@ func (d *DB) sortTablesByDependencies(ctx context.Context, tables []*model.Table
 		return nil
 	})
 	if err != nil {
-		return fmt.Errorf("failed to select INFORMATION_SCHEMA.TABLES: %w", err)
+		return nil, fmt.Errorf("failed to select INFORMATION_SCHEMA.TABLES: %w", err)
 	}
 
 	for _, ist := range tableMap {
@@ -94,7 +489,21 @@ func (d *DB) sortTablesByDependencies(ctx context.Context, tables []*model.Table
 		return isti.numDependentParents < istj.numDependentParents
 	})
 
-	return nil
+	fks, err := d.selectForeignKeys(ctx, tableNames)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get foreign keys: %w", err)
+	}
+
+	parents := make(map[string]string)
+	for _, ist := range tableMap {
+		if ist.ParentTableName.Valid {
+			parents[ist.TableName.StringVal] = ist.ParentTableName.StringVal
+		}
+	}
+
+	sortTablesByForeignKeys(tables, fks, parents, schemas)
+
+	return &dependencies{parents: parents, fks: fks}, nil
 }
 
 func numDependentParents(ist *informationSchemaTable, tableMap map[string]*informationSchemaTable) uint {
@@ -1,6 +1,7 @@
 package spanner
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"math/big"
@@ -11,6 +12,8 @@ import (
 	"cloud.google.com/go/civil"
 	"cloud.google.com/go/spanner"
 	"github.com/google/go-cmp/cmp"
+	spannerpb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/grpc/codes"
 
 	"github.com/kauche/splanter/internal/model"
 )
@@ -348,11 +351,16 @@ func TestSortTablesByDependencies(t *testing.T) {
 		},
 	}
 
-	if err := db.sortTablesByDependencies(ctx, actual); err != nil {
+	deps, err := db.sortTablesByDependencies(ctx, actual, nil)
+	if err != nil {
 		t.Errorf("failed to sort: %s", err)
 		return
 	}
 
+	if diff := cmp.Diff(deps.parents, map[string]string{"Bar": "Foo", "Baz": "Bar"}); diff != "" {
+		t.Errorf("\n(-actual, +expected)\n%s", diff)
+	}
+
 	expected := []*model.Table{
 		{
 			Name: "Foo",
@@ -370,6 +378,1097 @@ func TestSortTablesByDependencies(t *testing.T) {
 	}
 }
 
+func TestSortTablesByForeignKeys(t *testing.T) {
+	t.Parallel()
+
+	schemas := make(map[string]*tableSchema)
+	for _, c := range []struct {
+		table, column string
+		nullable      bool
+	}{
+		{"Books", "AuthorID", false},
+		{"Authors", "FavoriteBookID", true},
+		{"Employees", "ManagerID", true},
+	} {
+		cs := &columnSchema{name: c.column, typ: &columnType{code: spannerpb.TypeCode_STRING}, nullable: c.nullable}
+		schemas[c.table] = &tableSchema{name: c.table, columns: []*columnSchema{cs}, columnMap: map[string]*columnSchema{c.column: cs}}
+	}
+
+	fks := []*foreignKey{
+		{name: "FK_BooksAuthor", table: "Books", columns: []string{"AuthorID"}, referencedTable: "Authors", referencedColumns: []string{"ID"}},
+		{name: "FK_AuthorsFavoriteBook", table: "Authors", columns: []string{"FavoriteBookID"}, referencedTable: "Books", referencedColumns: []string{"ID"}},
+		{name: "FK_EmployeesManager", table: "Employees", columns: []string{"ManagerID"}, referencedTable: "Employees", referencedColumns: []string{"ID"}},
+	}
+
+	tests := []struct {
+		name     string
+		schemas  map[string]*tableSchema
+		expected []string
+	}{
+		// Authors is written first since Books.AuthorID is NOT NULL.
+		{name: "nullable", schemas: schemas, expected: []string{"Employees", "Authors", "Books"}},
+		{name: "without schemas", expected: []string{"Employees", "Books", "Authors"}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			tables := []*model.Table{{Name: "Employees"}, {Name: "Books"}, {Name: "Authors"}}
+			sortTablesByForeignKeys(tables, fks, nil, tt.schemas)
+
+			actual := make([]string, len(tables))
+			for i, table := range tables {
+				actual[i] = table.Name
+			}
+
+			if diff := cmp.Diff(actual, tt.expected); diff != "" {
+				t.Errorf("\n(-actual, +expected)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestOrderRecords(t *testing.T) {
+	t.Parallel()
+
+	columns := []*columnSchema{{name: "ID", typ: &columnType{code: spannerpb.TypeCode_STRING}}, {name: "ManagerID", typ: &columnType{code: spannerpb.TypeCode_STRING}, nullable: true}}
+	ts := &tableSchema{name: "Employees", columns: columns, columnMap: map[string]*columnSchema{"ID": columns[0], "ManagerID": columns[1]}, primaryKey: columns[:1]}
+	fks := []*foreignKey{{name: "FK", table: "Employees", columns: []string{"ManagerID"}, referencedTable: "Employees", referencedColumns: []string{"ID"}}}
+
+	records := []*model.Record{
+		{Values: map[string]interface{}{"ID": "a", "ManagerID": "b"}},
+		{Values: map[string]interface{}{"ID": "b", "ManagerID": "c"}},
+		{Values: map[string]interface{}{"ID": "c"}},
+		{Values: map[string]interface{}{"ID": "d", "ManagerID": "e"}},
+		{Values: map[string]interface{}{"ID": "e", "ManagerID": "d"}},
+		// f is not in the cycle of g and h, so only g is deferred.
+		{Values: map[string]interface{}{"ID": "f", "ManagerID": "g"}},
+		{Values: map[string]interface{}{"ID": "g", "ManagerID": "h"}},
+		{Values: map[string]interface{}{"ID": "h", "ManagerID": "g"}},
+	}
+
+	deferred := make(map[*model.Record][]string)
+	ordered := orderRecords(ts, records, fks, deferred)
+
+	actual := make([]string, len(ordered))
+	for i, record := range ordered {
+		actual[i] = record.Values["ID"].(string)
+	}
+
+	if diff := cmp.Diff(actual, []string{"c", "b", "a", "d", "e", "g", "f", "h"}); diff != "" {
+		t.Errorf("\n(-actual, +expected)\n%s", diff)
+	}
+
+	if diff := cmp.Diff(deferred, map[*model.Record][]string{records[3]: {"ManagerID"}, records[6]: {"ManagerID"}}); diff != "" {
+		t.Errorf("\n(-actual, +expected)\n%s", diff)
+	}
+}
+
+func TestMergeDuplicateRecords(t *testing.T) {
+	t.Parallel()
+
+	columns := []*columnSchema{{name: "ID", typ: &columnType{code: spannerpb.TypeCode_INT64}}, {name: "Name", typ: &columnType{code: spannerpb.TypeCode_STRING}}}
+	schemas := map[string]*tableSchema{
+		"Foo": {name: "Foo", columns: columns, columnMap: map[string]*columnSchema{"ID": columns[0], "Name": columns[1]}, primaryKey: columns[:1]},
+	}
+
+	newTables := func(override bool) []*model.Table {
+		return []*model.Table{
+			{
+				Name:               "Foo",
+				OverrideDuplicates: override,
+				Records: []*model.Record{
+					{Position: model.Position{File: "a/Foo.yaml", Line: 2}, Values: map[string]interface{}{"ID": int64(1), "Name": "a"}},
+					{Position: model.Position{File: "a/Foo.yaml", Line: 4}, Values: map[string]interface{}{"ID": int64(2)}},
+				},
+			},
+			{
+				Name:               "Foo",
+				OverrideDuplicates: override,
+				Records: []*model.Record{
+					{Position: model.Position{File: "b/Foo.yaml", Line: 2}, Values: map[string]interface{}{"ID": "1"}},
+				},
+			},
+		}
+	}
+
+	err := mergeDuplicateRecords(newTables(false), schemas)
+	if err == nil || err.Error() != "b/Foo.yaml:2: duplicate primary key (1) of Foo, which is also at a/Foo.yaml:2" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	tables := newTables(true)
+	if err := mergeDuplicateRecords(tables, schemas); err != nil {
+		t.Fatalf("failed to merge: %s", err)
+	}
+
+	expected := []*model.Table{
+		{
+			Name:               "Foo",
+			OverrideDuplicates: true,
+			Records: []*model.Record{
+				{Position: model.Position{File: "a/Foo.yaml", Line: 2}, Values: map[string]interface{}{"ID": "1", "Name": "a"}},
+				{Position: model.Position{File: "a/Foo.yaml", Line: 4}, Values: map[string]interface{}{"ID": int64(2)}},
+			},
+		},
+		{
+			Name:               "Foo",
+			OverrideDuplicates: true,
+			Records:            []*model.Record{},
+		},
+	}
+
+	if diff := cmp.Diff(tables, expected); diff != "" {
+		t.Errorf("\n(-actual, +expected)\n%s", diff)
+	}
+}
+
+func TestCheckUniqueIndexes(t *testing.T) {
+	t.Parallel()
+
+	columns := []*columnSchema{{name: "ID", typ: &columnType{code: spannerpb.TypeCode_INT64}}, {name: "Email", typ: &columnType{code: spannerpb.TypeCode_STRING}}}
+
+	tests := []struct {
+		name         string
+		nullFiltered bool
+		emails       []interface{}
+		expected     string
+	}{
+		{name: "unique", emails: []interface{}{"a", "b", nil}, expected: ""},
+		{name: "duplicate", emails: []interface{}{"a", "b", "a"}, expected: `Foo.yaml:3: duplicate values ("a") of unique index UQ_Email of Foo, which are also at Foo.yaml:1`},
+		{name: "duplicate null", emails: []interface{}{nil, "a", nil}, expected: "Foo.yaml:3: duplicate values (NULL) of unique index UQ_Email of Foo, which are also at Foo.yaml:1"},
+		{name: "null filtered", nullFiltered: true, emails: []interface{}{nil, "a", nil}, expected: ""},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ts := &tableSchema{name: "Foo", columns: columns, uniqueIndexes: []*uniqueIndex{{name: "UQ_Email", columns: columns[1:], nullFiltered: tt.nullFiltered}}}
+
+			table := &model.Table{Name: "Foo"}
+			for i, email := range tt.emails {
+				table.Records = append(table.Records, &model.Record{
+					Position: model.Position{File: "Foo.yaml", Line: i + 1},
+					Values:   map[string]interface{}{"ID": int64(i), "Email": email},
+				})
+			}
+
+			var actual string
+			if err := checkUniqueIndexes([]*model.Table{table}, map[string]*tableSchema{"Foo": ts}); err != nil {
+				actual = err.Error()
+			}
+
+			if actual != tt.expected {
+				t.Errorf("expected %q, but got %q", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestCommitError(t *testing.T) {
+	t.Parallel()
+
+	tables := []*model.Table{
+		{Name: "Foo", Records: []*model.Record{{Position: model.Position{File: "a/Foo.yaml", Line: 1}}, {Position: model.Position{File: "b/Foo.yaml", Line: 1}}}},
+		{Name: "Bar", Records: []*model.Record{{Position: model.Position{File: "a/Foo.yaml", Line: 3}}, {}}},
+	}
+
+	tests := []struct {
+		name     string
+		tables   []*model.Table
+		err      error
+		expected string
+	}{
+		{name: "files", tables: tables, err: fmt.Errorf("aborted"), expected: "failed to insert records of a/Foo.yaml, b/Foo.yaml: aborted"},
+		{name: "located", tables: tables, err: &model.PositionError{Position: model.Position{File: "a/Foo.yaml", Line: 1}, Err: fmt.Errorf("invalid")}, expected: "failed to insert records: a/Foo.yaml:1: invalid"},
+		{name: "no files", err: fmt.Errorf("aborted"), expected: "failed to insert records: aborted"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if actual := commitError(tt.tables, tt.err).Error(); actual != tt.expected {
+				t.Errorf("expected %q, but got %q", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestApplyColumnRules(t *testing.T) {
+	t.Parallel()
+
+	columns := []*columnSchema{{name: "ID"}, {name: "CreatedAt"}, {name: "UpdatedAt"}, {name: "TenantID"}, {name: "FullNameAt", generated: true}}
+	ts := &tableSchema{name: "Foo", columns: columns}
+
+	tables := []*model.Table{
+		{
+			Name: "Foo",
+			Records: []*model.Record{
+				{
+					Values: map[string]interface{}{
+						"ID":        "1",
+						"UpdatedAt": "2022-04-01T00:00:00Z",
+					},
+				},
+			},
+			Rules: []*model.ColumnRule{
+				{Column: "*At", Value: spanner.CommitTimestamp},
+				{Table: "Bar", Column: "TenantID", Value: "tenant1"},
+				{Table: "F*", Column: "*", Value: "any"},
+			},
+		},
+	}
+
+	applyColumnRules(tables, map[string]*tableSchema{"Foo": ts})
+
+	expected := map[string]interface{}{
+		"ID":        "1",
+		"CreatedAt": spanner.CommitTimestamp,
+		"UpdatedAt": "2022-04-01T00:00:00Z",
+		"TenantID":  "any",
+	}
+
+	if diff := cmp.Diff(tables[0].Records[0].Values, expected); diff != "" {
+		t.Errorf("\n(-actual, +expected)\n%s", diff)
+	}
+}
+
+func TestApplyCommitTimestamps(t *testing.T) {
+	t.Parallel()
+
+	columns := []*columnSchema{
+		{name: "CreatedAt", typ: &columnType{code: spannerpb.TypeCode_TIMESTAMP}},
+		{name: "Note", typ: &columnType{code: spannerpb.TypeCode_STRING}},
+	}
+	ts := &tableSchema{name: "Foo", columns: columns}
+
+	tables := []*model.Table{
+		{
+			Name: "Foo",
+			Records: []*model.Record{
+				{Values: map[string]interface{}{"CreatedAt": "PENDING_COMMIT_TIMESTAMP()", "Note": "PENDING_COMMIT_TIMESTAMP()"}},
+			},
+		},
+	}
+
+	applyCommitTimestamps(tables, map[string]*tableSchema{"Foo": ts})
+
+	// The string in the STRING column is written as it is.
+	expected := map[string]interface{}{
+		"CreatedAt": spanner.CommitTimestamp,
+		"Note":      "PENDING_COMMIT_TIMESTAMP()",
+	}
+
+	if diff := cmp.Diff(tables[0].Records[0].Values, expected); diff != "" {
+		t.Errorf("\n(-actual, +expected)\n%s", diff)
+	}
+}
+
+func TestSaveCommitTimestamp(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	db := testDB(t, ctx)
+
+	before := time.Now()
+
+	err := db.Save(ctx, []*model.Table{
+		{
+			Name: "CommitTimestamps",
+			Records: []*model.Record{
+				{
+					Values: map[string]interface{}{
+						"ID":        "6b1f7c8e-3d2a-4f5b-8e9c-1a2b3c4d5e6f",
+						"CreatedAt": spanner.CommitTimestamp,
+						"UpdatedAt": "PENDING_COMMIT_TIMESTAMP()",
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Errorf("failed to save: %s", err)
+		return
+	}
+
+	row, err := db.client.Single().ReadRow(ctx, "CommitTimestamps", spanner.Key{"6b1f7c8e-3d2a-4f5b-8e9c-1a2b3c4d5e6f"}, []string{"CreatedAt", "UpdatedAt"})
+	if err != nil {
+		t.Errorf("failed to read CommitTimestamps: %s", err)
+		return
+	}
+
+	var createdAt, updatedAt time.Time
+	if err := row.Columns(&createdAt, &updatedAt); err != nil {
+		t.Errorf("failed to decode CommitTimestamps: %s", err)
+		return
+	}
+
+	if createdAt.Before(before) || !createdAt.Equal(updatedAt) {
+		t.Errorf("expected the commit timestamp, but got CreatedAt: %s, UpdatedAt: %s", createdAt, updatedAt)
+	}
+
+	err = db.Save(ctx, []*model.Table{
+		{
+			Name: "CommitTimestamps",
+			Records: []*model.Record{
+				{
+					Values: map[string]interface{}{
+						"ID":        "6b1f7c8e-3d2a-4f5b-8e9c-1a2b3c4d5e6f",
+						"CreatedAt": spanner.CommitTimestamp,
+						"DeletedAt": spanner.CommitTimestamp,
+					},
+				},
+			},
+		},
+	})
+	if err == nil {
+		t.Errorf("expected an error for the column which does not allow the commit timestamp")
+	}
+}
+
+func TestSaveGeneratedColumns(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	db := testDB(t, ctx)
+
+	err := db.Save(ctx, []*model.Table{
+		{
+			Name: "GeneratedColumns",
+			Records: []*model.Record{
+				{
+					Values: map[string]interface{}{
+						"ID":        "0c5b2f1e-7a3d-4e6f-9b8a-2d1c0e9f8a7b",
+						"FirstName": "Foo",
+						"LastName":  "Bar",
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Errorf("failed to save: %s", err)
+		return
+	}
+
+	row, err := db.client.Single().ReadRow(ctx, "GeneratedColumns", spanner.Key{"0c5b2f1e-7a3d-4e6f-9b8a-2d1c0e9f8a7b"}, []string{"FullName", "Status"})
+	if err != nil {
+		t.Errorf("failed to read GeneratedColumns: %s", err)
+		return
+	}
+
+	var fullName, status string
+	if err := row.Columns(&fullName, &status); err != nil {
+		t.Errorf("failed to decode GeneratedColumns: %s", err)
+		return
+	}
+
+	if fullName != "Foo Bar" || status != "ACTIVE" {
+		t.Errorf("expected the generated and default values, but got FullName: %s, Status: %s", fullName, status)
+	}
+
+	err = db.Save(ctx, []*model.Table{
+		{
+			Name: "GeneratedColumns",
+			Records: []*model.Record{
+				{
+					Values: map[string]interface{}{
+						"ID":       "0c5b2f1e-7a3d-4e6f-9b8a-2d1c0e9f8a7b",
+						"FullName": "Foo Bar",
+					},
+				},
+			},
+		},
+	})
+	if err == nil {
+		t.Errorf("expected an error for the generated column")
+	}
+}
+
+func TestDiffColumnRules(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	db := testDB(t, ctx)
+
+	tables := func(name string) []*model.Table {
+		return []*model.Table{
+			{
+				Name:    "Foo",
+				Records: []*model.Record{{Values: map[string]interface{}{"FooID": "diff-rules"}}},
+				Rules:   []*model.ColumnRule{{Column: "Name", Value: name}},
+			},
+		}
+	}
+
+	if err := db.Save(ctx, tables("ruled")); err != nil {
+		t.Fatalf("failed to save: %s", err)
+	}
+
+	diffs, err := db.Diff(ctx, tables("ruled"), false)
+	if err != nil {
+		t.Fatalf("failed to diff: %s", err)
+	}
+	if !diffs[0].Empty() {
+		t.Errorf("expected no differences of the column filled by the rule, but got %+v", diffs[0])
+	}
+
+	diffs, err = db.Diff(ctx, tables("changed"), false)
+	if err != nil {
+		t.Fatalf("failed to diff: %s", err)
+	}
+
+	expected := []*RowDiff{{Key: `("diff-rules")`, Columns: []*ColumnDiff{{Column: "Name", Expected: `"changed"`, Actual: `"ruled"`}}}}
+	if diff := cmp.Diff(diffs[0].Changed, expected); diff != "" {
+		t.Errorf("\n(-actual, +expected)\n%s", diff)
+	}
+
+	// The values of the volatile rules such as `!now` differ on every load, so they are not compared.
+	volatile := tables("changed")
+	volatile[0].Rules[0].Volatile = true
+
+	diffs, err = db.Diff(ctx, volatile, false)
+	if err != nil {
+		t.Fatalf("failed to diff: %s", err)
+	}
+	if !diffs[0].Empty() {
+		t.Errorf("expected no differences of the column filled by the volatile rule, but got %+v", diffs[0])
+	}
+}
+
+func TestDiffTables(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	db := testDB(t, ctx)
+
+	// The records of DiffRows are in two files, which are compared with the rows at once.
+	tables := func() []*model.Table {
+		return []*model.Table{
+			{Name: "DiffRows", Records: []*model.Record{{Values: map[string]interface{}{"ID": "diff-a", "Name": "a"}}}},
+			{Name: "DiffRows", Records: []*model.Record{{Values: map[string]interface{}{"ID": "diff-b", "Name": "b"}}}},
+		}
+	}
+
+	if err := db.Save(ctx, tables()); err != nil {
+		t.Fatalf("failed to save: %s", err)
+	}
+
+	diffs, err := db.Diff(ctx, tables(), true)
+	if err != nil {
+		t.Fatalf("failed to diff: %s", err)
+	}
+	if len(diffs) != 1 || !diffs[0].Empty() {
+		t.Errorf("expected no differences of DiffRows, but got %+v", diffs)
+	}
+
+	// The records whose keys are unknown until saved are skipped.
+	position := model.Position{File: "SequenceParents.yaml", Line: 1}
+	diffs, err = db.Diff(ctx, []*model.Table{
+		{Name: "SequenceParents", Records: []*model.Record{{Position: position, Values: map[string]interface{}{"Name": "diff"}}}},
+	}, false)
+	if err != nil {
+		t.Fatalf("failed to diff: %s", err)
+	}
+
+	expected := []*TableDiff{{Table: "SequenceParents", Skipped: []*SkippedRecord{{Position: position, Reason: "the key is resolved on save"}}}}
+	if diff := cmp.Diff(diffs, expected); diff != "" {
+		t.Errorf("\n(-actual, +expected)\n%s", diff)
+	}
+}
+
+func TestSaveDefaultColumns(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	db := testDB(t, ctx)
+
+	const id = "5e0c1d7a-3b2f-4c8e-9a6d-1f4b7c2e8d90"
+	save := func(firstName string) {
+		t.Helper()
+
+		err := db.Save(ctx, []*model.Table{
+			{
+				Name: "GeneratedColumns",
+				Records: []*model.Record{
+					{Values: map[string]interface{}{"ID": id, "FirstName": firstName, "LastName": "Bar"}},
+				},
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to save: %s", err)
+		}
+	}
+
+	read := func() (string, string) {
+		t.Helper()
+
+		row, err := db.client.Single().ReadRow(ctx, "GeneratedColumns", spanner.Key{id}, []string{"FirstName", "Status"})
+		if err != nil {
+			t.Fatalf("failed to read GeneratedColumns: %s", err)
+		}
+
+		var firstName, status string
+		if err := row.Columns(&firstName, &status); err != nil {
+			t.Fatalf("failed to decode GeneratedColumns: %s", err)
+		}
+		return firstName, status
+	}
+
+	// The DEFAULT is applied to the new row which omits the column.
+	save("Foo")
+	if _, status := read(); status != "ACTIVE" {
+		t.Errorf("expected the default ACTIVE, but got %s", status)
+	}
+
+	_, err := db.client.Apply(ctx, []*spanner.Mutation{spanner.Update("GeneratedColumns", []string{"ID", "Status"}, []interface{}{id, "DELETED"})})
+	if err != nil {
+		t.Fatalf("failed to update GeneratedColumns: %s", err)
+	}
+
+	// The existing row keeps the value of the omitted column, instead of being reset to the DEFAULT or NULL.
+	save("Baz")
+	firstName, status := read()
+	if firstName != "Baz" {
+		t.Errorf("expected the saved Baz, but got %s", firstName)
+	}
+	if status != "DELETED" {
+		t.Errorf("expected the kept DELETED, but got %s", status)
+	}
+}
+
+func TestSaveKeyRules(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	db := testDB(t, ctx)
+
+	const fooID = "key-rules-foo"
+	rules := []*model.ColumnRule{{Table: "Bar", Column: "FooID", Value: fooID}}
+
+	// The FooID of Bar is filled by the rule, and the duplicates are found by the filled key.
+	err := db.Save(ctx, []*model.Table{
+		{
+			Name:    "Foo",
+			Rules:   rules,
+			Records: []*model.Record{{Values: map[string]interface{}{"FooID": fooID}}},
+		},
+		{
+			Name:               "Bar",
+			OverrideDuplicates: true,
+			Rules:              rules,
+			Records: []*model.Record{
+				{Values: map[string]interface{}{"BarID": "key-rules-bar", "Name": "first"}},
+				{Values: map[string]interface{}{"BarID": "key-rules-bar", "Name": "second"}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to save: %s", err)
+	}
+
+	row, err := db.client.Single().ReadRow(ctx, "Bar", spanner.Key{fooID, "key-rules-bar"}, []string{"Name"})
+	if err != nil {
+		t.Fatalf("failed to read Bar: %s", err)
+	}
+
+	var name string
+	if err := row.Columns(&name); err != nil {
+		t.Fatalf("failed to decode Bar: %s", err)
+	}
+	if name != "second" {
+		t.Errorf("expected the overridden second, but got %s", name)
+	}
+}
+
+func TestSaveSequence(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	db := testDB(t, ctx)
+
+	// The parent generating its key refers to the owner written in the same transaction.
+	tables := []*model.Table{
+		{
+			Name: "SequenceOwners",
+			Records: []*model.Record{
+				{Values: map[string]interface{}{"ID": "sequence-owner"}},
+			},
+		},
+		{
+			Name: "SequenceParents",
+			Records: []*model.Record{
+				{
+					Name: "parent",
+					Values: map[string]interface{}{
+						"Name":    "parent",
+						"OwnerID": "sequence-owner",
+					},
+				},
+			},
+		},
+		{
+			Name: "SequenceChildren",
+			Records: []*model.Record{
+				{
+					Values: map[string]interface{}{
+						"ParentID": model.Reference{Name: "parent", Column: "ID"},
+						"Name":     "child",
+					},
+				},
+			},
+		},
+	}
+
+	if err := db.Save(ctx, tables); err != nil {
+		t.Errorf("failed to save: %s", err)
+		return
+	}
+
+	id, ok := tables[1].Records[0].Values["ID"].(spanner.NullInt64)
+	if !ok || !id.Valid {
+		t.Errorf("expected the generated ID, but got %v", tables[1].Records[0].Values["ID"])
+		return
+	}
+
+	row, err := db.client.Single().ReadRow(ctx, "SequenceChildren", spanner.Key{id.Int64, "child"}, []string{"ParentID"})
+	if err != nil {
+		t.Errorf("failed to read SequenceChildren: %s", err)
+		return
+	}
+
+	var parentID int64
+	if err := row.Columns(&parentID); err != nil {
+		t.Errorf("failed to decode SequenceChildren: %s", err)
+		return
+	}
+
+	if parentID != id.Int64 {
+		t.Errorf("expected ParentID %d, but got %d", id.Int64, parentID)
+	}
+}
+
+func TestSaveForeignKeyCycles(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	db := testDB(t, ctx)
+
+	tests := []struct {
+		name   string
+		prefix string
+		// named forces the records to be written with statements, which check the foreign keys on each statement.
+		named bool
+	}{
+		{name: "mutations", prefix: "m-"},
+		{name: "statements", prefix: "s-", named: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			id := func(s string) string { return tt.prefix + s }
+
+			author := &model.Record{Values: map[string]interface{}{"ID": id("author1"), "FavoriteBookID": id("book1")}}
+			if tt.named {
+				author.Name = id("author1")
+			}
+
+			tables := []*model.Table{
+				{
+					Name: "Books",
+					Records: []*model.Record{
+						{Values: map[string]interface{}{"ID": id("book1"), "AuthorID": id("author1")}},
+					},
+				},
+				{
+					Name:    "Authors",
+					Records: []*model.Record{author},
+				},
+				{
+					Name: "Employees",
+					Records: []*model.Record{
+						{Values: map[string]interface{}{"ID": id("employee2"), "ManagerID": id("employee1")}},
+						{Values: map[string]interface{}{"ID": id("employee1"), "ManagerID": id("employee3")}},
+						{Values: map[string]interface{}{"ID": id("employee3"), "ManagerID": id("employee1")}},
+					},
+				},
+			}
+
+			if err := db.Save(ctx, tables); err != nil {
+				t.Errorf("failed to save: %s", err)
+				return
+			}
+
+			actual := make(map[string]string)
+			for table, column := range map[string]string{"Authors": "FavoriteBookID", "Books": "AuthorID", "Employees": "ManagerID"} {
+				err := db.client.Single().Read(ctx, table, spanner.KeyRange{Start: spanner.Key{tt.prefix}, End: spanner.Key{tt.prefix + "~"}}, []string{"ID", column}).Do(func(row *spanner.Row) error {
+					var id string
+					var ref spanner.NullString
+					if err := row.Columns(&id, &ref); err != nil {
+						return err
+					}
+					actual[table+"."+id] = ref.StringVal
+					return nil
+				})
+				if err != nil {
+					t.Errorf("failed to read %s: %s", table, err)
+					return
+				}
+			}
+
+			expected := map[string]string{
+				"Authors." + id("author1"):     id("book1"),
+				"Books." + id("book1"):         id("author1"),
+				"Employees." + id("employee1"): id("employee3"),
+				"Employees." + id("employee2"): id("employee1"),
+				"Employees." + id("employee3"): id("employee1"),
+			}
+
+			if diff := cmp.Diff(actual, expected); diff != "" {
+				t.Errorf("\n(-actual, +expected)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSaveOrphans(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	db := testDB(t, ctx)
+
+	// The parent row exists only in the database, which is looked up instead of the records.
+	_, err := db.client.Apply(ctx, []*spanner.Mutation{spanner.InsertOrUpdate("Foo", []string{"FooID", "Name"}, []interface{}{"orphans-db-foo", "foo"})})
+	if err != nil {
+		t.Fatalf("failed to insert Foo: %s", err)
+	}
+
+	tables := []*model.Table{
+		{
+			Name: "Foo",
+			Records: []*model.Record{
+				{Position: model.Position{File: "Foo.yaml", Line: 2}, Values: map[string]interface{}{"FooID": "orphans-foo", "Name": "foo"}},
+			},
+		},
+		{
+			Name: "Bar",
+			Records: []*model.Record{
+				{Position: model.Position{File: "Bar.yaml", Line: 2}, Values: map[string]interface{}{"FooID": "orphans-foo", "BarID": "orphans-bar1"}},
+				{Position: model.Position{File: "Bar.yaml", Line: 5}, Values: map[string]interface{}{"FooID": "orphans-missing", "BarID": "orphans-bar2"}},
+				{Position: model.Position{File: "Bar.yaml", Line: 8}, Values: map[string]interface{}{"FooID": "orphans-db-foo", "BarID": "orphans-bar3"}},
+			},
+		},
+		{
+			Name: "Boo",
+			Records: []*model.Record{
+				{Position: model.Position{File: "Boo.yaml", Line: 2}, Values: map[string]interface{}{"BooID": "orphans-boo", "BazID": "orphans-missing-baz"}},
+			},
+		},
+	}
+
+	err = db.Save(ctx, tables)
+	if err == nil {
+		t.Fatal("expected an error for the orphans")
+	}
+
+	expected := `invalid records: 2 records refer to rows which are not found:
+Bar.yaml:5: Bar refers to Foo ("orphans-missing") by INTERLEAVE IN PARENT Foo, which is not found
+Boo.yaml:2: Boo refers to Baz ("orphans-missing-baz") by FK_BooBaz, which is not found`
+	if err.Error() != expected {
+		t.Errorf("expected %q, but got %q", expected, err.Error())
+	}
+}
+
+func TestSnapshotAndRestore(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	db := testDB(t, ctx)
+
+	err := db.Save(ctx, []*model.Table{
+		{
+			Name: "Snapshot",
+			Records: []*model.Record{
+				{
+					Values: map[string]interface{}{
+						"SnapshotID": "4f1d4a0b-4c0e-4d7a-9c56-3f1b8c2f5f11",
+						"Name":       "unchanged",
+					},
+				},
+				{
+					Values: map[string]interface{}{
+						"SnapshotID": "a8b7b2de-0d5d-4f5e-8f37-5d0d3f6c9e22",
+						"Name":       "changed",
+					},
+				},
+				{
+					Values: map[string]interface{}{
+						"SnapshotID": "c3e0f4a5-1b2c-4d3e-9f40-6a7b8c9d0e33",
+						"Name":       "deleted",
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Errorf("failed to save: %s", err)
+		return
+	}
+
+	snapshot, err := db.Snapshot(ctx, []string{"Snapshot"})
+	if err != nil {
+		t.Errorf("failed to take snapshot: %s", err)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err = snapshot.Write(&buf); err != nil {
+		t.Errorf("failed to write snapshot: %s", err)
+		return
+	}
+
+	snapshot, err = ReadSnapshot(&buf)
+	if err != nil {
+		t.Errorf("failed to read snapshot: %s", err)
+		return
+	}
+
+	_, err = db.client.Apply(ctx, []*spanner.Mutation{
+		spanner.Update("Snapshot", []string{"SnapshotID", "Name"}, []interface{}{"a8b7b2de-0d5d-4f5e-8f37-5d0d3f6c9e22", "updated"}),
+		spanner.Delete("Snapshot", spanner.Key{"c3e0f4a5-1b2c-4d3e-9f40-6a7b8c9d0e33"}),
+		spanner.Insert("Snapshot", []string{"SnapshotID", "Name"}, []interface{}{"e5f6a7b8-2c3d-4e5f-8a9b-7c8d9e0f1a44", "added"}),
+	})
+	if err != nil {
+		t.Errorf("failed to mutate: %s", err)
+		return
+	}
+
+	if err = db.Restore(ctx, snapshot); err != nil {
+		t.Errorf("failed to restore: %s", err)
+		return
+	}
+
+	type snapshotRow struct {
+		SnapshotID string `spanner:"SnapshotID"`
+		Name       string `spanner:"Name"`
+	}
+
+	var actual []*snapshotRow
+	err = db.client.Single().Query(ctx, spanner.Statement{
+		SQL: "SELECT * FROM Snapshot ORDER BY Name",
+	}).Do(func(row *spanner.Row) error {
+		r := new(snapshotRow)
+		if err = row.ToStruct(r); err != nil {
+			return fmt.Errorf("failed to populate struct snapshotRow by rows: %w", err)
+		}
+		actual = append(actual, r)
+
+		return nil
+	})
+	if err != nil {
+		t.Errorf("failed to select Snapshot: %s", err)
+		return
+	}
+
+	expected := []*snapshotRow{
+		{
+			SnapshotID: "a8b7b2de-0d5d-4f5e-8f37-5d0d3f6c9e22",
+			Name:       "changed",
+		},
+		{
+			SnapshotID: "c3e0f4a5-1b2c-4d3e-9f40-6a7b8c9d0e33",
+			Name:       "deleted",
+		},
+		{
+			SnapshotID: "4f1d4a0b-4c0e-4d7a-9c56-3f1b8c2f5f11",
+			Name:       "unchanged",
+		},
+	}
+	if diff := cmp.Diff(actual, expected); diff != "" {
+		t.Errorf("\n(-actual, +expected)\n%s", diff)
+	}
+}
+
+func TestSnapshotRecordsAndRestore(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	db := testDB(t, ctx)
+
+	err := db.Save(ctx, []*model.Table{
+		{
+			Name: "Foo",
+			Records: []*model.Record{
+				{Values: map[string]interface{}{"FooID": "undo-overwritten", "Name": "before"}},
+				{Values: map[string]interface{}{"FooID": "undo-other", "Name": "other"}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to save: %s", err)
+	}
+
+	tables := []*model.Table{
+		{
+			Name: "Foo",
+			Records: []*model.Record{
+				{Values: map[string]interface{}{"FooID": "undo-overwritten", "Name": "after"}},
+				{Values: map[string]interface{}{"FooID": "undo-inserted", "Name": "inserted"}},
+			},
+		},
+	}
+
+	snapshot, err := db.SnapshotRecords(ctx, tables)
+	if err != nil {
+		t.Fatalf("failed to take snapshot: %s", err)
+	}
+
+	if err := db.Save(ctx, tables); err != nil {
+		t.Fatalf("failed to save: %s", err)
+	}
+
+	if err := db.AddRecordKeys(ctx, snapshot, tables); err != nil {
+		t.Fatalf("failed to add keys: %s", err)
+	}
+
+	if err := db.Restore(ctx, snapshot); err != nil {
+		t.Fatalf("failed to restore: %s", err)
+	}
+
+	actual := make(map[string]string)
+	err = db.client.Single().Read(ctx, "Foo", spanner.KeySetFromKeys(spanner.Key{"undo-overwritten"}, spanner.Key{"undo-inserted"}, spanner.Key{"undo-other"}), []string{"FooID", "Name"}).Do(func(row *spanner.Row) error {
+		var f foo
+		if err := row.ToStruct(&f); err != nil {
+			return err
+		}
+		actual[f.FooID] = f.Name
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to read Foo: %s", err)
+	}
+
+	// The inserted row is deleted, the overwritten row is restored, and the row of the other key is untouched.
+	expected := map[string]string{
+		"undo-overwritten": "before",
+		"undo-other":       "other",
+	}
+	if diff := cmp.Diff(actual, expected); diff != "" {
+		t.Errorf("\n(-actual, +expected)\n%s", diff)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	db := testDB(t, ctx)
+
+	newTables := func() []*model.Table {
+		return []*model.Table{
+			{
+				Name: "Baz",
+				Records: []*model.Record{
+					{Values: map[string]interface{}{"FooID": "delete-foo", "BarID": "delete-bar", "BazID": "delete-baz"}},
+				},
+			},
+			{
+				Name: "Foo",
+				Records: []*model.Record{
+					{Values: map[string]interface{}{"FooID": "delete-foo"}},
+				},
+			},
+			{
+				// The FooID of Bar is filled by the rule, for Delete as well as Save.
+				Name:  "Bar",
+				Rules: []*model.ColumnRule{{Table: "Bar", Column: "FooID", Value: "delete-foo"}},
+				Records: []*model.Record{
+					{Values: map[string]interface{}{"BarID": "delete-bar"}},
+				},
+			},
+		}
+	}
+
+	if err := db.Save(ctx, newTables()); err != nil {
+		t.Fatalf("failed to save: %s", err)
+	}
+
+	err := db.Save(ctx, []*model.Table{
+		{
+			Name: "Foo",
+			Records: []*model.Record{
+				{Values: map[string]interface{}{"FooID": "delete-other", "Name": "other"}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to save: %s", err)
+	}
+
+	tables := newTables()
+	skipped, err := db.Delete(ctx, tables)
+	if err != nil {
+		t.Fatalf("failed to delete: %s", err)
+	}
+	if len(skipped) != 0 {
+		t.Errorf("expected no skipped records, but got %+v", skipped)
+	}
+
+	// The rows are deleted from the last table, that is from children to parents.
+	actualOrder := make([]string, len(tables))
+	for i, table := range tables {
+		actualOrder[i] = table.Name
+	}
+	if diff := cmp.Diff(actualOrder, []string{"Foo", "Bar", "Baz"}); diff != "" {
+		t.Errorf("\n(-actual, +expected)\n%s", diff)
+	}
+
+	for table, key := range map[string]spanner.Key{
+		"Foo": {"delete-foo"},
+		"Bar": {"delete-foo", "delete-bar"},
+		"Baz": {"delete-foo", "delete-bar", "delete-baz"},
+	} {
+		_, err := db.client.Single().ReadRow(ctx, table, key, []string{"Name"})
+		if spanner.ErrCode(err) != codes.NotFound {
+			t.Errorf("expected the row %v of %s to be deleted, but got %v", key, table, err)
+		}
+	}
+
+	row, err := db.client.Single().ReadRow(ctx, "Foo", spanner.Key{"delete-other"}, []string{"Name"})
+	if err != nil {
+		t.Fatalf("expected the row not in the records to be left, but got %s", err)
+	}
+
+	var name string
+	if err := row.Columns(&name); err != nil {
+		t.Fatalf("failed to decode Foo: %s", err)
+	}
+	if name != "other" {
+		t.Errorf("expected other, but got %s", name)
+	}
+
+	// The records whose keys are generated on save are skipped.
+	position := model.Position{File: "SequenceParents.yaml", Line: 1}
+	skipped, err = db.Delete(ctx, []*model.Table{
+		{Name: "SequenceParents", Records: []*model.Record{{Position: position, Values: map[string]interface{}{"Name": "delete"}}}},
+	})
+	if err != nil {
+		t.Fatalf("failed to delete: %s", err)
+	}
+	if diff := cmp.Diff(skipped, []*SkippedRecord{{Position: position, Reason: "the key is resolved on save"}}); diff != "" {
+		t.Errorf("\n(-actual, +expected)\n%s", diff)
+	}
+}
+
 func testDB(t *testing.T, ctx context.Context) *DB {
 	t.Helper()
 
@@ -46,3 +46,65 @@ CREATE TABLE AllTypes (
   NumericArray ARRAY<NUMERIC>,
   JSONArray ARRAY<JSON>,
 ) PRIMARY KEY(ID);
+
+CREATE TABLE Snapshot (
+  SnapshotID STRING(36) NOT NULL,
+  Name STRING(MAX),
+) PRIMARY KEY(SnapshotID);
+
+CREATE TABLE CommitTimestamps (
+  ID STRING(36) NOT NULL,
+  CreatedAt TIMESTAMP NOT NULL OPTIONS (allow_commit_timestamp=true),
+  UpdatedAt TIMESTAMP OPTIONS (allow_commit_timestamp=true),
+  DeletedAt TIMESTAMP,
+) PRIMARY KEY(ID);
+
+CREATE TABLE GeneratedColumns (
+  ID STRING(36) NOT NULL,
+  FirstName STRING(MAX),
+  LastName STRING(MAX),
+  FullName STRING(MAX) AS (CONCAT(FirstName, " ", LastName)) STORED,
+  Status STRING(MAX) NOT NULL DEFAULT ("ACTIVE"),
+) PRIMARY KEY(ID);
+
+CREATE SEQUENCE SequenceParentSeq OPTIONS (sequence_kind = "bit_reversed_positive");
+
+CREATE TABLE SequenceOwners (
+  ID STRING(36) NOT NULL,
+) PRIMARY KEY(ID);
+
+CREATE TABLE SequenceParents (
+  ID INT64 NOT NULL DEFAULT (GET_NEXT_SEQUENCE_VALUE(SEQUENCE SequenceParentSeq)),
+  Name STRING(MAX) NOT NULL,
+  OwnerID STRING(36),
+  CONSTRAINT FK_SequenceParentsOwner FOREIGN KEY (OwnerID) REFERENCES SequenceOwners (ID)
+) PRIMARY KEY(ID);
+
+CREATE TABLE SequenceChildren (
+  ParentID INT64 NOT NULL,
+  Name STRING(MAX) NOT NULL,
+) PRIMARY KEY(ParentID, Name);
+
+CREATE TABLE Employees (
+  ID STRING(36) NOT NULL,
+  ManagerID STRING(36),
+  CONSTRAINT FK_EmployeesManager FOREIGN KEY (ManagerID) REFERENCES Employees (ID)
+) PRIMARY KEY(ID);
+
+CREATE TABLE Authors (
+  ID STRING(36) NOT NULL,
+  FavoriteBookID STRING(36),
+) PRIMARY KEY(ID);
+
+CREATE TABLE Books (
+  ID STRING(36) NOT NULL,
+  AuthorID STRING(36) NOT NULL,
+  CONSTRAINT FK_BooksAuthor FOREIGN KEY (AuthorID) REFERENCES Authors (ID)
+) PRIMARY KEY(ID);
+
+ALTER TABLE Authors ADD CONSTRAINT FK_AuthorsFavoriteBook FOREIGN KEY (FavoriteBookID) REFERENCES Books (ID);
+
+CREATE TABLE DiffRows (
+  ID STRING(36) NOT NULL,
+  Name STRING(MAX),
+) PRIMARY KEY(ID);
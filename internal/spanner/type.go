@@ -0,0 +1,145 @@
+package spanner
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	spannerpb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Type codes which are not defined in the version of spannerpb in use.
+const (
+	typeCodeProto    spannerpb.TypeCode = 13
+	typeCodeEnum     spannerpb.TypeCode = 14
+	typeCodeFloat32  spannerpb.TypeCode = 15
+	typeCodeInterval spannerpb.TypeCode = 16
+)
+
+var vectorLengthRegexp = regexp.MustCompile(`vector_length\s*=>\s*(\d+)`)
+
+// columnType is a column type parsed from INFORMATION_SCHEMA.COLUMNS.SPANNER_TYPE such as `STRING(36)` or `ARRAY<INT64>`.
+type columnType struct {
+	raw  string
+	code spannerpb.TypeCode
+	elem *columnType
+
+	// length is the declared maximum length of STRING and BYTES. 0 means MAX.
+	length int64
+	// vectorLength is the declared length of vector arrays such as `ARRAY<FLOAT32>(vector_length=>768)`. 0 means any length.
+	vectorLength int64
+
+	// protoName is the full name of the PROTO or ENUM type, and message or enum is its descriptor if it is found.
+	protoName string
+	message   protoreflect.MessageDescriptor
+	enum      protoreflect.EnumDescriptor
+}
+
+func parseColumnType(raw string) (*columnType, error) {
+	t := &columnType{raw: raw}
+
+	if strings.HasPrefix(raw, "ARRAY<") {
+		end := matchingAngleBracket(raw, len("ARRAY"))
+		if end < 0 {
+			return nil, fmt.Errorf("invalid array type: %s", raw)
+		}
+
+		elem, err := parseColumnType(raw[len("ARRAY<"):end])
+		if err != nil {
+			return nil, err
+		}
+
+		t.code = spannerpb.TypeCode_ARRAY
+		t.elem = elem
+
+		if m := vectorLengthRegexp.FindStringSubmatch(raw[end+1:]); m != nil {
+			n, err := strconv.ParseInt(m[1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid vector_length of type %s: %w", raw, err)
+			}
+			t.vectorLength = n
+		}
+
+		return t, nil
+	}
+
+	for prefix, code := range map[string]spannerpb.TypeCode{"PROTO<": typeCodeProto, "ENUM<": typeCodeEnum} {
+		if strings.HasPrefix(raw, prefix) && strings.HasSuffix(raw, ">") {
+			t.code = code
+			t.protoName = raw[len(prefix) : len(raw)-1]
+			return t, nil
+		}
+	}
+
+	name := raw
+	if i := strings.Index(raw, "("); i >= 0 {
+		name = raw[:i]
+
+		length := strings.TrimSuffix(raw[i+1:], ")")
+		if length != "MAX" {
+			n, err := strconv.ParseInt(length, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid length of type %s: %w", raw, err)
+			}
+			t.length = n
+		}
+	}
+
+	switch strings.ToUpper(name) {
+	case "BOOL":
+		t.code = spannerpb.TypeCode_BOOL
+	case "INT64":
+		t.code = spannerpb.TypeCode_INT64
+	case "FLOAT64":
+		t.code = spannerpb.TypeCode_FLOAT64
+	case "FLOAT32":
+		t.code = typeCodeFloat32
+	case "TIMESTAMP":
+		t.code = spannerpb.TypeCode_TIMESTAMP
+	case "DATE":
+		t.code = spannerpb.TypeCode_DATE
+	case "STRING":
+		t.code = spannerpb.TypeCode_STRING
+	case "BYTES":
+		t.code = spannerpb.TypeCode_BYTES
+	case "NUMERIC":
+		t.code = spannerpb.TypeCode_NUMERIC
+	case "JSON":
+		t.code = spannerpb.TypeCode_JSON
+	case "INTERVAL":
+		t.code = typeCodeInterval
+	default:
+		t.code = spannerpb.TypeCode_TYPE_CODE_UNSPECIFIED
+
+		// PROTO and ENUM columns may be typed with the bare full name, whose kind is known only from the descriptors.
+		if strings.Contains(name, ".") {
+			t.protoName = name
+		}
+	}
+
+	return t, nil
+}
+
+// matchingAngleBracket returns the index of `>` which closes `<` at open, or -1.
+func matchingAngleBracket(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '<':
+			depth++
+		case '>':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+func (t *columnType) String() string {
+	return t.raw
+}
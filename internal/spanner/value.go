@@ -0,0 +1,58 @@
+package spanner
+
+import (
+	"fmt"
+
+	"cloud.google.com/go/spanner"
+	spannerpb "google.golang.org/genproto/googleapis/spanner/v1"
+)
+
+// keyPart decodes a column value read from Spanner into a type which can be used as a part of spanner.Key.
+func keyPart(v spanner.GenericColumnValue) (interface{}, error) {
+	var (
+		part interface{}
+		err  error
+	)
+
+	switch v.Type.GetCode() {
+	case spannerpb.TypeCode_BOOL:
+		var b spanner.NullBool
+		err = v.Decode(&b)
+		part = b
+	case spannerpb.TypeCode_INT64:
+		var n spanner.NullInt64
+		err = v.Decode(&n)
+		part = n
+	case spannerpb.TypeCode_FLOAT64:
+		var f spanner.NullFloat64
+		err = v.Decode(&f)
+		part = f
+	case spannerpb.TypeCode_TIMESTAMP:
+		var t spanner.NullTime
+		err = v.Decode(&t)
+		part = t
+	case spannerpb.TypeCode_DATE:
+		var d spanner.NullDate
+		err = v.Decode(&d)
+		part = d
+	case spannerpb.TypeCode_STRING:
+		var s spanner.NullString
+		err = v.Decode(&s)
+		part = s
+	case spannerpb.TypeCode_BYTES:
+		var b []byte
+		err = v.Decode(&b)
+		part = b
+	case spannerpb.TypeCode_NUMERIC:
+		var n spanner.NullNumeric
+		err = v.Decode(&n)
+		part = n
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", v.Type.GetCode())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode key value: %w", err)
+	}
+
+	return part, nil
+}
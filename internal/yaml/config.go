@@ -0,0 +1,214 @@
+package yaml
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+
+	"github.com/kauche/splanter/internal/model"
+)
+
+// ConfigFile is the file in the root of the loaded directory which configures all the tables.
+const ConfigFile = "_splanter.yaml"
+
+// config is the settings for all the tables in the config file.
+type config struct {
+	rules []*model.ColumnRule
+
+	// overrideDuplicates makes later records override the records of the same primary key instead of failing.
+	overrideDuplicates bool
+}
+
+// configTags are the tags which can be used in the config file, which mark the values of `!now` as volatile.
+var configTags = func() map[string]tagFunc {
+	tags := make(map[string]tagFunc, len(seedTags))
+	for name, f := range seedTags {
+		tags[name] = f
+	}
+	tags["!now"] = func(d *decoder, node *ast.TagNode) (interface{}, error) {
+		v, err := now(d, node)
+		return volatile{value: v}, err
+	}
+	return tags
+}()
+
+// volatile is a value which differs on every load.
+type volatile struct {
+	value interface{}
+}
+
+// readConfig reads the config file in dir, which is optional. If strict is true, duplicate keys and unknown tags are rejected.
+//
+//	duplicate_keys: override # or error (default)
+//	rules:
+//	  - column: "*At"
+//	    value: !now
+//	  - table: "User*"
+//	    column: TenantID
+//	    value: tenant1
+func readConfig(fsys fs.FS, dir string, strict bool) (*config, error) {
+	name := path.Join(dir, ConfigFile)
+
+	b, err := fs.ReadFile(fsys, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return new(config), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+
+	f, err := parser.ParseBytes(b, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	if len(f.Docs) == 0 || f.Docs[0].Body == nil {
+		return new(config), nil
+	}
+
+	d := newDecoder(configTags)
+	d.strict = strict
+	d.fsys = fsys
+	d.dir = dir
+	d.files = []string{name}
+	d.sources[name] = b
+
+	c, err := d.config(f.Docs[0].Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", name, err)
+	}
+
+	return c, nil
+}
+
+func (d *decoder) config(node ast.Node) (*config, error) {
+	pairs, ok := d.mappingValues(node)
+	if !ok {
+		return nil, d.errorf(node, "the config must be a mapping but got %s", node.Type())
+	}
+
+	c := new(config)
+	keyNodes := make(map[string]ast.Node)
+	for _, mv := range pairs {
+		key, err := d.value(mv.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		if d.strict {
+			if first, ok := keyNodes[fmt.Sprint(key)]; ok {
+				return nil, d.errorf(mv.Key, "duplicate key %v, which is also at line %d", key, d.position(first).Line)
+			}
+			keyNodes[fmt.Sprint(key)] = mv.Key
+		}
+
+		v, err := d.value(mv.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		switch key {
+		case "rules":
+		case "duplicate_keys":
+			switch v {
+			case "error":
+				c.overrideDuplicates = false
+			case "override":
+				c.overrideDuplicates = true
+			default:
+				return nil, d.errorf(mv.Value, "duplicate_keys must be error or override but got %v", v)
+			}
+			continue
+		default:
+			return nil, d.errorf(mv.Key, "unknown key %v in the config", key)
+		}
+
+		list, ok := v.([]interface{})
+		if !ok {
+			return nil, d.errorf(mv.Value, "rules must be a list but got %v", v)
+		}
+
+		for _, item := range list {
+			rule, err := columnRule(item)
+			if err != nil {
+				return nil, d.positionError(d.position(mv.Value), err)
+			}
+			c.rules = append(c.rules, rule)
+		}
+	}
+
+	return c, nil
+}
+
+func columnRule(v interface{}) (*model.ColumnRule, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("each rule must be a mapping but got %v", v)
+	}
+
+	rule := new(model.ColumnRule)
+	for k, value := range m {
+		switch k {
+		case "table", "column":
+			s, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s of rule must be a string but got %v", k, value)
+			}
+			if _, err := path.Match(s, ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern %s: %w", s, err)
+			}
+
+			if k == "table" {
+				rule.Table = s
+			} else {
+				rule.Column = s
+			}
+		case "value":
+			value, rule.Volatile = unwrapVolatile(value)
+			converted, err := convertValue(value)
+			if err != nil {
+				return nil, err
+			}
+			rule.Value = converted
+		default:
+			return nil, fmt.Errorf("unknown key %s in rule", k)
+		}
+	}
+
+	if rule.Column == "" {
+		return nil, fmt.Errorf("rule requires column: %v", v)
+	}
+
+	return rule, nil
+}
+
+// unwrapVolatile returns the value without the volatile marks, and whether the value has any of them.
+func unwrapVolatile(v interface{}) (interface{}, bool) {
+	switch v := v.(type) {
+	case volatile:
+		return v.value, true
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		var found bool
+		for i, e := range v {
+			var ok bool
+			list[i], ok = unwrapVolatile(e)
+			found = found || ok
+		}
+		return list, found
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		var found bool
+		for k, e := range v {
+			var ok bool
+			m[k], ok = unwrapVolatile(e)
+			found = found || ok
+		}
+		return m, found
+	default:
+		return v, false
+	}
+}
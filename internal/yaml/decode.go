@@ -0,0 +1,304 @@
+package yaml
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+
+	"github.com/kauche/splanter/internal/model"
+)
+
+// tagFunc converts the value of a node tagged with a custom tag such as `!regex`.
+type tagFunc func(d *decoder, node *ast.TagNode) (interface{}, error)
+
+// decoder converts yaml nodes into Go values in the same way as yaml.Unmarshal does for interface{},
+// but it also resolves the custom tags which yaml.Unmarshal does not support.
+type decoder struct {
+	tags    map[string]tagFunc
+	anchors map[string]ast.Node
+
+	// fsys and dir are the file system and the directory of the yaml file, which the paths in tags such as `!vector` are relative to.
+	fsys fs.FS
+	dir  string
+	// files is the yaml file and the files being included, to detect include cycles.
+	files []string
+	// read is the files read by the tags, in the order of reading.
+	read []string
+	// sources is the content of each file, to print the snippets of errors.
+	sources map[string][]byte
+	// strict rejects duplicate keys and unknown tags.
+	strict bool
+}
+
+// newDecoder returns a decoder of the tags, in addition to the type tags which can be used anywhere.
+func newDecoder(tags map[string]tagFunc) *decoder {
+	all := make(map[string]tagFunc, len(tags)+len(typeTags))
+	for name, f := range typeTags {
+		all[name] = f
+	}
+	for name, f := range tags {
+		all[name] = f
+	}
+
+	return &decoder{
+		tags:    all,
+		anchors: make(map[string]ast.Node),
+		sources: make(map[string][]byte),
+	}
+}
+
+// position returns the position of the node in the yaml file being decoded.
+func (d *decoder) position(node ast.Node) model.Position {
+	var pos model.Position
+	if len(d.files) > 0 {
+		pos.File = d.files[len(d.files)-1]
+	}
+
+	if tk := node.GetToken(); tk != nil && tk.Position != nil {
+		pos.Line = tk.Position.Line
+		pos.Column = tk.Position.Column
+	}
+
+	return pos
+}
+
+// errorf returns an error at the position of the node, which is printed with the snippet of the yaml file.
+func (d *decoder) errorf(node ast.Node, format string, args ...interface{}) error {
+	return d.positionError(d.position(node), fmt.Errorf(format, args...))
+}
+
+// positionError returns the error at the position, unless the error already has a more specific position.
+func (d *decoder) positionError(pos model.Position, err error) error {
+	var pe *model.PositionError
+	if errors.As(err, &pe) {
+		return err
+	}
+
+	return &model.PositionError{Position: pos, Source: d.sources[pos.File], Err: err}
+}
+
+// mappingValues returns the key-value pairs of the mapping node, or false if the node is not a mapping.
+// NOTE: A mapping with a single pair is parsed as *ast.MappingValueNode instead of *ast.MappingNode.
+func (d *decoder) mappingValues(node ast.Node) ([]*ast.MappingValueNode, bool) {
+	switch n := node.(type) {
+	case *ast.MappingNode:
+		return n.Values, true
+	case *ast.MappingValueNode:
+		return []*ast.MappingValueNode{n}, true
+	case *ast.AnchorNode:
+		d.anchors[n.Name.GetToken().Value] = n.Value
+		return d.mappingValues(n.Value)
+	case *ast.AliasNode:
+		anchor, ok := d.anchors[n.Value.GetToken().Value]
+		if !ok {
+			return nil, false
+		}
+		return d.mappingValues(anchor)
+	default:
+		return nil, false
+	}
+}
+
+// mapping converts the pairs of the mapping node in order, resolving merge keys (`<<`).
+func (d *decoder) mapping(values []*ast.MappingValueNode) (yaml.MapSlice, error) {
+	var (
+		merged   yaml.MapSlice
+		explicit yaml.MapSlice
+		// keyNodes is the first node of each explicit key, to report duplicate keys.
+		keyNodes = make(map[string]ast.Node)
+	)
+
+	values = append([]*ast.MappingValueNode(nil), values...)
+	for i := 0; i < len(values); i++ {
+		mv := values[i]
+
+		if tag, following, ok := d.splitEmptyTag(mv); ok {
+			mv = ast.MappingValue(mv.Start, mv.Key, tag)
+			values = append(values[:i+1], append(following, values[i+1:]...)...)
+		}
+
+		if mv.Key.Type() == ast.MergeKeyType {
+			if d.isInclude(mv.Value) {
+				v, err := d.value(mv.Value)
+				if err != nil {
+					return nil, err
+				}
+
+				m, ok := mapSlice(v)
+				if !ok {
+					return nil, d.errorf(mv.Value, "the value of merge key must be a mapping")
+				}
+				merged = append(merged, m...)
+
+				continue
+			}
+
+			pairs, ok := d.mappingValues(mv.Value)
+			if !ok {
+				return nil, d.errorf(mv.Value, "the value of merge key must be a mapping")
+			}
+
+			m, err := d.mapping(pairs)
+			if err != nil {
+				return nil, err
+			}
+			merged = append(merged, m...)
+
+			continue
+		}
+
+		key, err := d.value(mv.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		switch key.(type) {
+		case []interface{}, map[string]interface{}:
+			return nil, d.errorf(mv.Key, "key %v must be a scalar", key)
+		}
+
+		if d.strict {
+			if first, ok := keyNodes[fmt.Sprint(key)]; ok {
+				return nil, d.errorf(mv.Key, "duplicate key %v, which is also at line %d", key, d.position(first).Line)
+			}
+			keyNodes[fmt.Sprint(key)] = mv.Key
+		}
+
+		value, err := d.value(mv.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		explicit = append(explicit, yaml.MapItem{Key: key, Value: value})
+	}
+
+	if len(merged) == 0 {
+		return explicit, nil
+	}
+
+	// Explicit keys take precedence over the merged keys. The keys are compared as strings, the same as the mapping is converted,
+	// since the values of tags such as `!json` cannot be map keys.
+	keys := make(map[string]bool, len(explicit))
+	for _, item := range explicit {
+		keys[fmt.Sprint(item.Key)] = true
+	}
+
+	var items yaml.MapSlice
+	for _, item := range merged {
+		if !keys[fmt.Sprint(item.Key)] {
+			keys[fmt.Sprint(item.Key)] = true
+			items = append(items, item)
+		}
+	}
+
+	return append(items, explicit...), nil
+}
+
+// splitEmptyTag returns the tag without the value and the following pairs if the value of mv is a tag without a value.
+// NOTE: goccy/go-yaml parses a tag without a value (e.g. `ID: !any`) followed by other keys
+// as if the following keys were the value of the tag, so they need to be moved back to the mapping.
+func (d *decoder) splitEmptyTag(mv *ast.MappingValueNode) (*ast.TagNode, []*ast.MappingValueNode, bool) {
+	tag, ok := mv.Value.(*ast.TagNode)
+	if !ok || tag.Value == nil {
+		return nil, nil, false
+	}
+
+	var following []*ast.MappingValueNode
+	switch n := tag.Value.(type) {
+	case *ast.MappingNode:
+		following = n.Values
+	case *ast.MappingValueNode:
+		following = []*ast.MappingValueNode{n}
+	default:
+		return nil, nil, false
+	}
+
+	if len(following) == 0 || following[0].Key.GetToken().Position.Column > mv.Key.GetToken().Position.Column {
+		return nil, nil, false
+	}
+
+	return &ast.TagNode{BaseNode: tag.BaseNode, Start: tag.Start}, following, true
+}
+
+func (d *decoder) value(node ast.Node) (interface{}, error) {
+	switch n := node.(type) {
+	case nil:
+		return nil, nil
+	case *ast.NullNode:
+		return nil, nil
+	case *ast.IntegerNode:
+		return n.Value, nil
+	case *ast.FloatNode:
+		return n.Value, nil
+	case *ast.InfinityNode:
+		return n.Value, nil
+	case *ast.NanNode:
+		return math.NaN(), nil
+	case *ast.StringNode:
+		return n.Value, nil
+	case *ast.BoolNode:
+		return n.Value, nil
+	case *ast.LiteralNode:
+		return n.Value.Value, nil
+	case *ast.MappingKeyNode:
+		return d.value(n.Value)
+	case *ast.AnchorNode:
+		d.anchors[n.Name.GetToken().Value] = n.Value
+		return d.value(n.Value)
+	case *ast.AliasNode:
+		name := n.Value.GetToken().Value
+		anchor, ok := d.anchors[name]
+		if !ok {
+			return nil, d.errorf(n, "anchor %s is not defined", name)
+		}
+		return d.value(anchor)
+	case *ast.TagNode:
+		if f, ok := d.tags[n.Start.Value]; ok {
+			v, err := f(d, n)
+			if err != nil {
+				return nil, d.positionError(d.position(n), err)
+			}
+			return v, nil
+		}
+
+		// Tags of the yaml core schema such as `!!str` are resolved by go-yaml.
+		if d.strict && !strings.HasPrefix(n.Start.Value, "!!") {
+			return nil, d.errorf(n, "unknown tag %s", n.Start.Value)
+		}
+
+		var v interface{}
+		if err := yaml.NodeToValue(n, &v); err != nil {
+			return nil, d.positionError(d.position(n), err)
+		}
+		return v, nil
+	case *ast.SequenceNode:
+		values := make([]interface{}, len(n.Values))
+		for i, vn := range n.Values {
+			v, err := d.value(vn)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = v
+		}
+		return values, nil
+	case *ast.MappingNode, *ast.MappingValueNode:
+		pairs, _ := d.mappingValues(n)
+		items, err := d.mapping(pairs)
+		if err != nil {
+			return nil, err
+		}
+
+		m := make(map[string]interface{}, len(items))
+		for _, item := range items {
+			m[fmt.Sprint(item.Key)] = item.Value
+		}
+		return m, nil
+	default:
+		return nil, d.errorf(node, "unsupported yaml node %s", node.Type())
+	}
+}
@@ -0,0 +1,172 @@
+package yaml
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/goccy/go-yaml/ast"
+
+	"github.com/kauche/splanter/internal/model"
+)
+
+// matcherTags are the tags which can be used in expectation files instead of concrete values.
+var matcherTags = map[string]tagFunc{
+	"!any":    anyMatcher,
+	"!regex":  regexpMatcher,
+	"!within": withinMatcher,
+}
+
+// LoadExpectations loads expectation files in dir.
+// Each file is either a list of rows in the same format as seed files, or a mapping which has `rows`, `count` and `ignore`.
+func (l *Loader) LoadExpectations(ctx context.Context, dir string) ([]*model.Expectation, error) {
+	var expectations []*model.Expectation
+	err := walk(os.DirFS(dir), ".", l.strict, func(file, name string, source []byte, body ast.Node) error {
+		d := newDecoder(matcherTags)
+		d.strict = l.strict
+		d.files = []string{file}
+		d.sources[file] = source
+
+		expectation, err := d.expectation(name, body)
+		if err != nil {
+			return err
+		}
+
+		expectations = append(expectations, expectation)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk dir %s: %w", dir, err)
+	}
+
+	return expectations, nil
+}
+
+func (d *decoder) expectation(name string, node ast.Node) (*model.Expectation, error) {
+	expectation := &model.Expectation{Name: name}
+
+	pairs, ok := d.mappingValues(node)
+	if !ok {
+		records, err := d.records(node)
+		if err != nil {
+			return nil, err
+		}
+		expectation.Records = records
+
+		return expectation, nil
+	}
+
+	for _, mv := range pairs {
+		key, err := d.value(mv.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		switch key {
+		case "rows":
+			records, err := d.records(mv.Value)
+			if err != nil {
+				return nil, err
+			}
+			expectation.Records = records
+		case "count":
+			v, err := d.value(mv.Value)
+			if err != nil {
+				return nil, err
+			}
+			count, ok := v.(uint64)
+			if !ok {
+				return nil, d.errorf(mv.Value, "count must be a non-negative integer but got %v", v)
+			}
+			n := int64(count)
+			expectation.Count = &n
+		case "ignore":
+			v, err := d.value(mv.Value)
+			if err != nil {
+				return nil, err
+			}
+			columns, ok := v.([]interface{})
+			if !ok {
+				return nil, d.errorf(mv.Value, "ignore must be a list of column names but got %v", v)
+			}
+			for _, c := range columns {
+				column, ok := c.(string)
+				if !ok {
+					return nil, d.errorf(mv.Value, "ignore must be a list of column names but got %v", v)
+				}
+				expectation.Ignore = append(expectation.Ignore, column)
+			}
+		default:
+			return nil, d.errorf(mv.Key, "unknown key %v in expectation file", key)
+		}
+	}
+
+	return expectation, nil
+}
+
+func anyMatcher(d *decoder, node *ast.TagNode) (interface{}, error) {
+	return model.AnyMatcher{}, nil
+}
+
+func regexpMatcher(d *decoder, node *ast.TagNode) (interface{}, error) {
+	v, err := d.value(node.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	pattern, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("!regex requires a string but got %v", v)
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression %s: %w", pattern, err)
+	}
+
+	return model.RegexpMatcher{Regexp: re}, nil
+}
+
+// withinMatcher converts `!within 5m` (within 5 minutes of now) or `!within {time: 2022-04-01T00:00:00Z, duration: 1h}`.
+func withinMatcher(d *decoder, node *ast.TagNode) (interface{}, error) {
+	v, err := d.value(node.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	var matcher model.WithinMatcher
+	switch w := v.(type) {
+	case string:
+		matcher.Duration, err = time.ParseDuration(w)
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration of !within: %w", err)
+		}
+	case map[string]interface{}:
+		duration, ok := w["duration"].(string)
+		if !ok {
+			return nil, fmt.Errorf("!within requires duration but got %v", v)
+		}
+		matcher.Duration, err = time.ParseDuration(duration)
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration of !within: %w", err)
+		}
+
+		if t, ok := w["time"]; ok {
+			s, ok := t.(string)
+			if !ok {
+				return nil, fmt.Errorf("time of !within must be a RFC 3339 string but got %v", t)
+			}
+			matcher.Time, err = time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid time of !within: %w", err)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("!within requires a duration or a mapping but got %v", v)
+	}
+
+	return matcher, nil
+}
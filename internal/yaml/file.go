@@ -0,0 +1,47 @@
+package yaml
+
+import (
+	"fmt"
+	"io/fs"
+
+	"github.com/goccy/go-yaml/ast"
+
+	"github.com/kauche/splanter/internal/model"
+)
+
+// readFile reads the file at the path given as the value of the tag.
+func (d *decoder) readFile(node *ast.TagNode) (string, []byte, error) {
+	v, err := d.value(node.Value)
+	if err != nil {
+		return "", nil, err
+	}
+
+	name, ok := v.(string)
+	if !ok || name == "" {
+		return "", nil, d.errorf(node, "%s must be a path to a file but got %v", node.Start.Value, v)
+	}
+
+	if d.fsys == nil {
+		return "", nil, d.errorf(node, "%s is not supported here", node.Start.Value)
+	}
+
+	resolved := d.resolvePath(name)
+	d.read = append(d.read, resolved)
+
+	b, err := fs.ReadFile(d.fsys, resolved)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+
+	return name, b, nil
+}
+
+// file reads the file for BYTES, STRING and JSON columns, which is converted by the column type when it is written.
+func file(d *decoder, node *ast.TagNode) (interface{}, error) {
+	name, b, err := d.readFile(node)
+	if err != nil {
+		return nil, err
+	}
+
+	return model.File{Path: name, Content: b}, nil
+}
@@ -0,0 +1,114 @@
+package yaml
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+)
+
+// resolvePath resolves the path in a tag such as `!include`, which is relative to the yaml file,
+// or relative to the loaded directory if it starts with `/`.
+func (d *decoder) resolvePath(name string) string {
+	if strings.HasPrefix(name, "/") {
+		return path.Clean(strings.TrimPrefix(name, "/"))
+	}
+
+	return path.Join(d.dir, name)
+}
+
+// include decodes the yaml file at the path, e.g. a fragment in a directory whose name starts with `_`,
+// which is not loaded as a table. A list of rows included in a list is expanded into the list,
+// and a mapping of columns can be merged into a row with `<<: !include <path>`.
+func include(d *decoder, node *ast.TagNode) (interface{}, error) {
+	v, err := d.value(node.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	name, ok := v.(string)
+	if !ok || name == "" {
+		return nil, d.errorf(node, "!include must be a path to a yaml file but got %v", v)
+	}
+
+	if d.fsys == nil {
+		return nil, d.errorf(node, "!include is not supported here")
+	}
+
+	file := d.resolvePath(name)
+	for _, f := range d.files {
+		if f == file {
+			return nil, fmt.Errorf("include cycle: %s", strings.Join(append(d.files, file), " -> "))
+		}
+	}
+
+	d.read = append(d.read, file)
+
+	b, err := fs.ReadFile(d.fsys, file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+
+	f, err := parser.ParseBytes(b, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+
+	var body ast.Node
+	if len(f.Docs) > 0 {
+		body = f.Docs[0].Body
+	}
+
+	d.sources[file] = b
+	dir := d.dir
+	d.files = append(d.files, file)
+	d.dir = path.Dir(file)
+	defer func() {
+		d.files = d.files[:len(d.files)-1]
+		d.dir = dir
+	}()
+
+	value, err := d.value(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to include %s: %w", file, err)
+	}
+
+	return value, nil
+}
+
+// isInclude reports whether the node is tagged with `!include`.
+func (d *decoder) isInclude(node ast.Node) bool {
+	tag, ok := node.(*ast.TagNode)
+	if !ok || tag.Start.Value != "!include" {
+		return false
+	}
+
+	_, ok = d.tags["!include"]
+	return ok
+}
+
+// mapSlice converts a decoded mapping into the pairs sorted by the keys.
+func mapSlice(v interface{}) (yaml.MapSlice, bool) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	items := make(yaml.MapSlice, len(keys))
+	for i, k := range keys {
+		items[i] = yaml.MapItem{Key: k, Value: m[k]}
+	}
+
+	return items, true
+}
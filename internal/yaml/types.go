@@ -0,0 +1,154 @@
+package yaml
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
+	"github.com/goccy/go-yaml/ast"
+)
+
+// typeTags are the tags which convert the values into the Go types of Spanner explicitly,
+// for the values whose types cannot be inferred (e.g. NUMERIC instead of FLOAT64), or when the schema is not available.
+// They can be used both in seed files and expectation files.
+var typeTags = map[string]tagFunc{
+	"!!binary":   binaryValue,
+	"!date":      dateValue,
+	"!timestamp": timestampValue,
+	"!numeric":   numericValue,
+	"!json":      jsonValue,
+	"!int64":     int64Value,
+}
+
+// scalar returns the value of the tag, which must be a scalar such as a string or a number.
+func (d *decoder) scalar(node *ast.TagNode) (interface{}, error) {
+	v, err := d.value(node.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	switch v.(type) {
+	case string, uint64, int64, float64:
+		return v, nil
+	default:
+		return nil, fmt.Errorf("%s requires a scalar value but got %v", node.Start.Value, v)
+	}
+}
+
+// binaryValue converts the base64 encoded string of `!!binary` into bytes.
+func binaryValue(d *decoder, node *ast.TagNode) (interface{}, error) {
+	v, err := d.scalar(node)
+	if err != nil {
+		return nil, err
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("!!binary requires a base64 encoded string but got %v", v)
+	}
+
+	// Base64 in yaml may be folded into multiple lines.
+	b, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(s), ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 of !!binary: %w", err)
+	}
+
+	return b, nil
+}
+
+// dateValue converts `!date 2022-04-01` into civil.Date.
+func dateValue(d *decoder, node *ast.TagNode) (interface{}, error) {
+	v, err := d.scalar(node)
+	if err != nil {
+		return nil, err
+	}
+
+	date, err := civil.ParseDate(fmt.Sprint(v))
+	if err != nil {
+		return nil, fmt.Errorf("invalid date of !date: %w", err)
+	}
+
+	return date, nil
+}
+
+// timestampValue converts `!timestamp 2022-04-01T00:00:00Z`, a RFC 3339 string, into time.Time in UTC.
+func timestampValue(d *decoder, node *ast.TagNode) (interface{}, error) {
+	v, err := d.scalar(node)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, fmt.Sprint(v))
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp of !timestamp: %w", err)
+	}
+
+	return t.UTC(), nil
+}
+
+// numericValue converts `!numeric 1.23` into *big.Rat without the rounding of float64.
+// The source text is used rather than the parsed number to keep the exact decimal.
+func numericValue(d *decoder, node *ast.TagNode) (interface{}, error) {
+	if _, err := d.scalar(node); err != nil {
+		return nil, err
+	}
+
+	s := node.Value.GetToken().Value
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("!numeric requires a decimal number but got %s", s)
+	}
+
+	return r, nil
+}
+
+// jsonValue converts `!json` of a JSON string or a yaml value into spanner.NullJSON.
+func jsonValue(d *decoder, node *ast.TagNode) (interface{}, error) {
+	v, err := d.value(node.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	if s, ok := v.(string); ok {
+		var parsed interface{}
+		if err := json.Unmarshal([]byte(s), &parsed); err != nil {
+			return nil, fmt.Errorf("invalid JSON of !json: %w", err)
+		}
+		v = parsed
+	}
+
+	return spanner.NullJSON{Value: v, Valid: true}, nil
+}
+
+// int64Value converts `!int64` of an integer or a string of an integer into int64.
+func int64Value(d *decoder, node *ast.TagNode) (interface{}, error) {
+	v, err := d.scalar(node)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n := v.(type) {
+	case int64:
+		return n, nil
+	case uint64:
+		if n > math.MaxInt64 {
+			return nil, fmt.Errorf("%d of !int64 is out of range", n)
+		}
+		return int64(n), nil
+	case string:
+		parsed, err := strconv.ParseInt(n, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer of !int64: %w", err)
+		}
+		return parsed, nil
+	default:
+		return nil, fmt.Errorf("!int64 requires an integer but got %v", v)
+	}
+}
@@ -0,0 +1,157 @@
+package yaml
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-yaml/ast"
+)
+
+var (
+	npyMagic = []byte("\x93NUMPY")
+
+	npyDescrRegexp   = regexp.MustCompile(`'descr'\s*:\s*'([^']*)'`)
+	npyFortranRegexp = regexp.MustCompile(`'fortran_order'\s*:\s*(True|False)`)
+	npyShapeRegexp   = regexp.MustCompile(`'shape'\s*:\s*\(([^)]*)\)`)
+)
+
+// vector reads the list of floats from the file at the path relative to the yaml file,
+// which is either a `.npy` file, a `.json` file or a file of raw little-endian FLOAT32 values.
+func vector(d *decoder, node *ast.TagNode) (interface{}, error) {
+	name, b, err := d.readFile(node)
+	if err != nil {
+		return nil, err
+	}
+
+	var floats []float64
+	switch path.Ext(name) {
+	case ".npy":
+		floats, err = decodeNPY(b)
+	case ".json":
+		err = json.Unmarshal(b, &floats)
+	default:
+		floats, err = decodeFloat32s(b, binary.LittleEndian)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the vector file %s: %w", name, err)
+	}
+
+	return floats, nil
+}
+
+// decodeNPY decodes a one-dimensional array of float32 or float64 in the NumPy `.npy` format.
+func decodeNPY(b []byte) ([]float64, error) {
+	if !bytes.HasPrefix(b, npyMagic) || len(b) < len(npyMagic)+4 {
+		return nil, fmt.Errorf("not a npy file")
+	}
+
+	major := b[len(npyMagic)]
+	rest := b[len(npyMagic)+2:]
+
+	var headerLen int
+	switch major {
+	case 1:
+		headerLen = int(binary.LittleEndian.Uint16(rest))
+		rest = rest[2:]
+	case 2, 3:
+		if len(rest) < 4 {
+			return nil, fmt.Errorf("invalid npy header")
+		}
+		headerLen = int(binary.LittleEndian.Uint32(rest))
+		rest = rest[4:]
+	default:
+		return nil, fmt.Errorf("unsupported npy version %d", major)
+	}
+
+	if len(rest) < headerLen {
+		return nil, fmt.Errorf("invalid npy header")
+	}
+	header, data := string(rest[:headerLen]), rest[headerLen:]
+
+	if m := npyFortranRegexp.FindStringSubmatch(header); m != nil && m[1] == "True" {
+		return nil, fmt.Errorf("fortran order is not supported")
+	}
+
+	m := npyShapeRegexp.FindStringSubmatch(header)
+	if m == nil {
+		return nil, fmt.Errorf("shape is not found in the npy header")
+	}
+	shape := m[1]
+
+	var dims []string
+	for _, dim := range strings.Split(shape, ",") {
+		if dim = strings.TrimSpace(dim); dim != "" && dim != "1" {
+			dims = append(dims, dim)
+		}
+	}
+	if len(dims) > 1 {
+		return nil, fmt.Errorf("the array must be one-dimensional but the shape is (%s)", shape)
+	}
+
+	m = npyDescrRegexp.FindStringSubmatch(header)
+	if m == nil {
+		return nil, fmt.Errorf("descr is not found in the npy header")
+	}
+
+	var (
+		floats []float64
+		err    error
+	)
+	switch m[1] {
+	case "<f4":
+		floats, err = decodeFloat32s(data, binary.LittleEndian)
+	case ">f4":
+		floats, err = decodeFloat32s(data, binary.BigEndian)
+	case "<f8":
+		floats, err = decodeFloat64s(data, binary.LittleEndian)
+	case ">f8":
+		floats, err = decodeFloat64s(data, binary.BigEndian)
+	default:
+		return nil, fmt.Errorf("unsupported dtype %s", m[1])
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(dims) == 1 {
+		n, err := strconv.Atoi(dims[0])
+		if err != nil || n != len(floats) {
+			return nil, fmt.Errorf("the shape (%s) does not match the data of %d values", shape, len(floats))
+		}
+	}
+
+	return floats, nil
+}
+
+func decodeFloat32s(b []byte, order binary.ByteOrder) ([]float64, error) {
+	if len(b)%4 != 0 {
+		return nil, fmt.Errorf("the size %d is not a multiple of 4", len(b))
+	}
+
+	floats := make([]float64, len(b)/4)
+	for i := range floats {
+		floats[i] = float64(math.Float32frombits(order.Uint32(b[i*4:])))
+	}
+
+	return floats, nil
+}
+
+func decodeFloat64s(b []byte, order binary.ByteOrder) ([]float64, error) {
+	if len(b)%8 != 0 {
+		return nil, fmt.Errorf("the size %d is not a multiple of 8", len(b))
+	}
+
+	floats := make([]float64, len(b)/8)
+	for i := range floats {
+		floats[i] = math.Float64frombits(order.Uint64(b[i*8:]))
+	}
+
+	return floats, nil
+}
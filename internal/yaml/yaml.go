@@ -2,22 +2,61 @@ package yaml
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
+	"math/big"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
 	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
 
 	"github.com/kauche/splanter/internal/model"
 )
 
-type Loader struct{}
+// recordNameKey is the key which names the record so that other records can refer to it with `!ref <name>.<column>`.
+// It never conflicts with column names since they must start with a letter.
+const recordNameKey = "_name"
 
-func NewLoader() *Loader {
-	return &Loader{}
+// seedTags are the tags which can be used in seed files.
+var seedTags = map[string]tagFunc{
+	"!commit_timestamp": commitTimestamp,
+	"!ref":              reference,
+	"!vector":           vector,
+	"!file":             file,
+	"!include":          include,
+	"!now":              now,
+}
+
+type Loader struct {
+	// strict rejects duplicate keys, unknown tags and files with multiple documents, which are otherwise ignored.
+	strict bool
+}
+
+type Option func(*Loader)
+
+// WithStrict makes the loader reject duplicate keys in mappings, tags which are not supported,
+// and files with more than one document, instead of silently using the last key, the plain value or the first document.
+func WithStrict() Option {
+	return func(l *Loader) {
+		l.strict = true
+	}
+}
+
+func NewLoader(opts ...Option) *Loader {
+	l := new(Loader)
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
 }
 
 func assertTypedSlice[T any](slice []any) ([]T, error) {
@@ -25,7 +64,7 @@ func assertTypedSlice[T any](slice []any) ([]T, error) {
 	for _, v := range slice {
 		tVal, ok := v.(T)
 		if !ok {
-			return nil, fmt.Errorf("unsupported mixed types list: %v", slice)
+			return nil, fmt.Errorf("unsupported mixed types list: %v", v)
 		}
 		tSlice = append(tSlice, tVal)
 	}
@@ -33,103 +72,480 @@ func assertTypedSlice[T any](slice []any) ([]T, error) {
 }
 
 func (l *Loader) Load(ctx context.Context, dir string) ([]*model.Table, error) {
+	tables, err := l.loadDir(os.DirFS(dir), ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk dir %s: %w", dir, err)
+	}
+
+	return tables, nil
+}
+
+// LoadFS loads yaml files under dir in fsys.
+func (l *Loader) LoadFS(ctx context.Context, fsys fs.FS, dir string) ([]*model.Table, error) {
+	tables, err := l.loadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk dir %s: %w", dir, err)
+	}
+
+	return tables, nil
+}
+
+// LoadFile loads a yaml file at the slash-separated path relative to dir, with the config file in dir.
+// Paths in the tags such as `!include` are resolved against dir in the same way as Load.
+func (l *Loader) LoadFile(ctx context.Context, dir, path string) (*model.Table, error) {
+	fsys := os.DirFS(dir)
+
+	c, err := readConfig(fsys, ".", l.strict)
+	if err != nil {
+		return nil, err
+	}
+
+	tables, err := l.load(fsys, path, c)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tables) == 0 {
+		return nil, fmt.Errorf("%s is not a yaml file", path)
+	}
+
+	return tables[0], nil
+}
+
+func (l *Loader) loadDir(fsys fs.FS, dir string) ([]*model.Table, error) {
+	c, err := readConfig(fsys, dir, l.strict)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.load(fsys, dir, c)
+}
+
+// load loads the yaml files under dir in fsys, applying the config c to all the tables.
+func (l *Loader) load(fsys fs.FS, dir string, c *config) ([]*model.Table, error) {
 	var tables []*model.Table
-	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+	err := walk(fsys, dir, l.strict, func(file, name string, source []byte, body ast.Node) error {
+		d := newDecoder(seedTags)
+		d.strict = l.strict
+		d.fsys = fsys
+		d.dir = path.Dir(file)
+		d.files = []string{file}
+		d.sources[file] = source
+
+		table, err := d.table(name, body)
+		if err != nil {
+			return err
+		}
+		table.File = file
+		table.Includes = d.read
+		table.Source = source
+		table.Rules = c.rules
+		table.OverrideDuplicates = c.overrideDuplicates
+
+		tables = append(tables, table)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tables, nil
+}
+
+// table converts a seed file, which is either a list of rows or a mapping which has `defaults` and `rows`.
+// The defaults are set to the columns omitted in the rows.
+func (d *decoder) table(name string, node ast.Node) (*model.Table, error) {
+	table := &model.Table{Name: name}
+
+	pairs, ok := d.mappingValues(node)
+	if !ok {
+		records, err := d.records(node)
+		if err != nil {
+			return nil, err
+		}
+		table.Records = records
+
+		return table, nil
+	}
+
+	var (
+		defaults     map[string]interface{}
+		defaultsNode ast.Node
+	)
+	for _, mv := range pairs {
+		key, err := d.value(mv.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		switch key {
+		case "rows":
+			records, err := d.records(mv.Value)
+			if err != nil {
+				return nil, err
+			}
+			table.Records = records
+		case "defaults":
+			v, err := d.value(mv.Value)
+			if err != nil {
+				return nil, err
+			}
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, d.errorf(mv.Value, "defaults must be a mapping but got %v", v)
+			}
+			defaults = m
+			defaultsNode = mv.Value
+		default:
+			return nil, d.errorf(mv.Key, "unknown key %v in seed file: the top level must be a list of rows, or a mapping which has rows and defaults", key)
+		}
+	}
+
+	for column, v := range defaults {
+		converted, err := convertValue(v)
+		if err != nil {
+			return nil, d.errorf(defaultsNode, "invalid default of %s: %w", column, err)
+		}
+
+		for _, record := range table.Records {
+			if _, ok := record.Values[column]; !ok {
+				record.Values[column] = converted
+			}
+		}
+	}
+
+	return table, nil
+}
+
+// walk parses each yaml file under dir in fsys and calls fn with the path of the file, the table name,
+// the content of the file and the body of the document.
+// Errors of the files don't stop the walk, and all of them are returned together so that they can be fixed at once.
+// If strict is true, files with more than one document are rejected.
+func walk(fsys fs.FS, dir string, strict bool, fn func(file, name string, source []byte, body ast.Node) error) error {
+	var errs []error
+	err := fs.WalkDir(fsys, dir, func(path string, entry fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
 		fname := entry.Name()
+
+		// Files and directories starting with `_` are fragments or the config file, which are not loaded as tables.
+		if path != dir && strings.HasPrefix(fname, "_") {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
 		ext := filepath.Ext(fname)
-		if !entry.IsDir() && (ext == ".yaml" || ext == ".yml") {
-			var items []yaml.MapSlice
-			file, err := os.Open(path)
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			return nil
+		}
+
+		file, err := fsys.Open(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to open yaml file: %w", err))
+			return nil
+		}
+		defer file.Close()
+
+		seeds, err := io.ReadAll(file)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to read yaml file: %w", err))
+			return nil
+		}
+
+		f, err := parser.ParseBytes(seeds, 0)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to unmarshal yaml file %s: %w", path, err))
+			return nil
+		}
+
+		var body ast.Node
+		if len(f.Docs) > 0 {
+			body = f.Docs[0].Body
+		}
+
+		if strict && len(f.Docs) > 1 {
+			pos := model.Position{File: path}
+			if tk := f.Docs[1].GetToken(); tk != nil && tk.Position != nil {
+				pos.Line = tk.Position.Line
+				pos.Column = tk.Position.Column
+			}
+			errs = append(errs, &model.PositionError{Position: pos, Source: seeds, Err: fmt.Errorf("a yaml file must have only one document but got %d", len(f.Docs))})
+			return nil
+		}
+
+		name := filepath.Base(strings.TrimSuffix(fname, ext))
+		if err := fn(path, name, seeds, body); err != nil {
+			errs = append(errs, fileError(path, err))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return errors.Join(errs...)
+}
+
+// fileError adds the path of the file to the error, unless the error is located in a file already.
+func fileError(path string, err error) error {
+	var pe *model.PositionError
+	if errors.As(err, &pe) {
+		return err
+	}
+
+	return fmt.Errorf("failed to load %s: %w", path, err)
+}
+
+// records converts the list of mappings into records.
+func (d *decoder) records(node ast.Node) ([]*model.Record, error) {
+	if node == nil {
+		return nil, nil
+	}
+
+	// Records of the valid items are still converted to report the errors in them too.
+	items, err := d.recordItems(node)
+	var errs []error
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	records := make([]*model.Record, len(items))
+	for i, item := range items {
+		records[i] = &model.Record{
+			Values:    make(map[string]interface{}),
+			Position:  item.position,
+			Positions: item.positions,
+		}
+
+		for _, p := range item.pairs {
+			key, ok := p.Key.(string)
+			if !ok {
+				pos, ok := item.positions[fmt.Sprint(p.Key)]
+				if !ok {
+					pos = item.position
+				}
+				errs = append(errs, d.positionError(pos, fmt.Errorf("column name must be a string but got %v", p.Key)))
+				continue
+			}
+
+			pos, ok := item.positions[key]
+			if !ok {
+				pos = item.position
+			}
+
+			if key == recordNameKey {
+				name, ok := p.Value.(string)
+				if !ok || name == "" {
+					errs = append(errs, d.positionError(pos, fmt.Errorf("%s must be a non-empty string but got %v", recordNameKey, p.Value)))
+					continue
+				}
+				records[i].Name = name
+				continue
+			}
+
+			v, err := convertValue(p.Value)
 			if err != nil {
-				return fmt.Errorf("failed to open yaml file: %w", err)
+				errs = append(errs, d.positionError(pos, fmt.Errorf("invalid value of %s: %w", key, err)))
+				continue
 			}
-			defer file.Close()
+			records[i].Values[key] = v
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
 
-			seeds, err := io.ReadAll(file)
+	return records, nil
+}
+
+// recordItem is the pairs of a row with the positions where the row and its columns are written.
+type recordItem struct {
+	pairs     yaml.MapSlice
+	position  model.Position
+	positions map[string]model.Position
+}
+
+// recordItems returns the pairs of each mapping in the list, expanding the lists of rows included with `!include`.
+// The rows included are located at the `!include` tag.
+func (d *decoder) recordItems(node ast.Node) ([]recordItem, error) {
+	if d.isInclude(node) {
+		v, err := d.value(node)
+		if err != nil {
+			return nil, err
+		}
+		return d.includedItems(node, v)
+	}
+
+	seq, ok := node.(*ast.SequenceNode)
+	if !ok {
+		return nil, d.errorf(node, "failed to unmarshal yaml file: the top level must be a list but got %s", node.Type())
+	}
+
+	var (
+		items []recordItem
+		errs  []error
+	)
+	for _, item := range seq.Values {
+		if d.isInclude(item) {
+			v, err := d.value(item)
 			if err != nil {
-				return fmt.Errorf("failed to read yaml file: %w", err)
+				errs = append(errs, err)
+				continue
 			}
 
-			if err = yaml.Unmarshal(seeds, &items); err != nil {
-				return fmt.Errorf("failed to unmarshal yaml file: %w", err)
+			included, err := d.includedItems(item, v)
+			if err != nil {
+				errs = append(errs, err)
+				continue
 			}
+			items = append(items, included...)
 
-			records := make([]*model.Record, len(items))
-			for i, proparties := range items {
-				records[i] = &model.Record{
-					Values: make(map[string]interface{}),
-				}
+			continue
+		}
 
-				for _, p := range proparties {
-					key, ok := p.Key.(string)
-					if !ok {
-						return fmt.Errorf("failed to unmarshal yaml proparty: %w", err)
-					}
-
-					// Spanner does not support the type uint64
-					val, ok := p.Value.(uint64)
-					if ok {
-						records[i].Values[key] = int64(val)
-						continue
-					}
-
-					// Spanner does not support the type []any
-					list, ok := p.Value.([]any)
-					if ok {
-						switch list[0].(type) {
-						case bool:
-							records[i].Values[key], err = assertTypedSlice[bool](list)
-							if err != nil {
-								return err
-							}
-						case string:
-							records[i].Values[key], err = assertTypedSlice[string](list)
-							if err != nil {
-								return err
-							}
-						case float64:
-							records[i].Values[key], err = assertTypedSlice[float64](list)
-							if err != nil {
-								return err
-							}
-						case uint64:
-							// Spanner does not support the type uint64 so assert to int64
-							int64List := make([]int64, 0, len(list))
-							for _, v := range list {
-								n, ok := v.(uint64)
-								if !ok {
-									return fmt.Errorf("unsupported mixed types list: %v", v)
-								}
-								int64List = append(int64List, int64(n))
-							}
-							records[i].Values[key] = int64List
-						default:
-							return fmt.Errorf("unsupported type in list: %v", list)
-						}
-						continue
-					}
-
-					records[i].Values[key] = p.Value
-				}
+		pairs, ok := d.mappingValues(item)
+		if !ok {
+			errs = append(errs, d.errorf(item, "failed to unmarshal yaml file: each item must be a mapping but got %s", item.Type()))
+			continue
+		}
+
+		proparties, err := d.mapping(pairs)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
 
+		positions := make(map[string]model.Position, len(pairs))
+		for _, mv := range pairs {
+			if mv.Key.Type() != ast.MergeKeyType {
+				positions[mv.Key.GetToken().Value] = d.position(mv.Key)
 			}
+		}
+		// The token of a mapping node is the `:` of the first pair, so the row is located at the first key.
+		items = append(items, recordItem{pairs: proparties, position: d.position(pairs[0].Key), positions: positions})
+	}
+
+	return items, errors.Join(errs...)
+}
+
+// includedItems converts the row or list of rows included by the node into the pairs of each row.
+// Lists in the included list, which are included by the included file with `- !include`, are expanded as well.
+func (d *decoder) includedItems(node ast.Node, v interface{}) ([]recordItem, error) {
+	pos := d.position(node)
+	if m, ok := mapSlice(v); ok {
+		return []recordItem{{pairs: m, position: pos}}, nil
+	}
+
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, d.errorf(node, "the included file must be a row or a list of rows but got %v", v)
+	}
 
-			name := filepath.Base(strings.TrimSuffix(fname, ext))
-			tables = append(tables, &model.Table{
-				Name:    name,
-				Records: records,
-			})
+	items := make([]recordItem, 0, len(list))
+	for _, row := range list {
+		if m, ok := mapSlice(row); ok {
+			items = append(items, recordItem{pairs: m, position: pos})
+			continue
 		}
 
-		return nil
-	})
+		if _, ok := row.([]interface{}); !ok {
+			return nil, d.errorf(node, "each included row must be a mapping but got %v", row)
+		}
+
+		nested, err := d.includedItems(node, row)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, nested...)
+	}
+
+	return items, nil
+}
+
+// convertValue converts the value decoded from yaml into the type which Spanner supports.
+func convertValue(value interface{}) (interface{}, error) {
+	// Spanner does not support the type uint64
+	val, ok := value.(uint64)
+	if ok {
+		return int64(val), nil
+	}
+
+	// Spanner does not support the type []any
+	list, ok := value.([]any)
+	if !ok {
+		return value, nil
+	}
+
+	// An empty list has no type of the elements, and is written as an empty array of any column type.
+	if len(list) == 0 {
+		return []string{}, nil
+	}
+
+	// Spanner does not support the type uint64, and the untagged integers are mixed with the ones tagged with !int64.
+	normalized := make([]any, len(list))
+	for i, v := range list {
+		if n, ok := v.(uint64); ok {
+			v = int64(n)
+		}
+		normalized[i] = v
+	}
+	list = normalized
+
+	switch list[0].(type) {
+	case bool:
+		return assertTypedSlice[bool](list)
+	case int64:
+		return assertTypedSlice[int64](list)
+	case civil.Date:
+		return assertTypedSlice[civil.Date](list)
+	case time.Time:
+		return assertTypedSlice[time.Time](list)
+	case *big.Rat:
+		return assertTypedSlice[*big.Rat](list)
+	case []byte:
+		return assertTypedSlice[[]byte](list)
+	case spanner.NullJSON:
+		return assertTypedSlice[spanner.NullJSON](list)
+	case string:
+		return assertTypedSlice[string](list)
+	case float64:
+		return assertTypedSlice[float64](list)
+	default:
+		return nil, fmt.Errorf("unsupported type in list: %v", list)
+	}
+}
+
+func commitTimestamp(d *decoder, node *ast.TagNode) (interface{}, error) {
+	return spanner.CommitTimestamp, nil
+}
+
+// reference converts `!ref <name>.<column>` into a reference to the column of the named record.
+func reference(d *decoder, node *ast.TagNode) (interface{}, error) {
+	v, err := d.value(node.Value)
 	if err != nil {
-		return nil, fmt.Errorf("failed to walk dir %s: %w", dir, err)
+		return nil, err
 	}
 
-	return tables, nil
+	s, ok := v.(string)
+	if !ok {
+		return nil, d.errorf(node, "!ref must be <name>.<column> but got %v", v)
+	}
+
+	i := strings.LastIndex(s, ".")
+	if i <= 0 || i == len(s)-1 {
+		return nil, d.errorf(node, "!ref must be <name>.<column> but got %s", s)
+	}
+
+	return model.Reference{Name: s[:i], Column: s[i+1:]}, nil
+}
+
+// now converts `!now` into the current time.
+func now(d *decoder, node *ast.TagNode) (interface{}, error) {
+	return time.Now().UTC(), nil
 }
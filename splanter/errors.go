@@ -0,0 +1,27 @@
+package splanter
+
+// FixtureError is returned when the yaml files cannot be read or are invalid.
+type FixtureError struct {
+	Err error
+}
+
+func (e *FixtureError) Error() string {
+	return "failed to load yaml files: " + e.Err.Error()
+}
+
+func (e *FixtureError) Unwrap() error {
+	return e.Err
+}
+
+// SaveError is returned when the rows cannot be written to the database.
+type SaveError struct {
+	Err error
+}
+
+func (e *SaveError) Error() string {
+	return "failed to load data to spanner tables: " + e.Err.Error()
+}
+
+func (e *SaveError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,147 @@
+// Package splanter loads data from yaml files into Google Cloud Spanner tables.
+//
+// It is the library version of the splanter command, mainly for seeding databases from test code.
+//
+//	result, err := splanter.Load(ctx, client, os.DirFS("testdata/seeds"))
+package splanter
+
+import (
+	"context"
+	"io/fs"
+
+	"cloud.google.com/go/spanner"
+
+	"github.com/kauche/splanter/internal/model"
+	spannerdb "github.com/kauche/splanter/internal/spanner"
+	"github.com/kauche/splanter/internal/yaml"
+)
+
+// Option configures Load.
+type Option func(*options)
+
+type options struct {
+	dir    string
+	strict bool
+}
+
+// WithDirectory loads the yaml files under dir in the file system instead of the root.
+func WithDirectory(dir string) Option {
+	return func(o *options) {
+		o.dir = dir
+	}
+}
+
+// WithStrict rejects duplicate keys, unknown tags and files with multiple documents in the yaml files.
+func WithStrict() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		dir: ".",
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+func (o *options) loader() *yaml.Loader {
+	if o.strict {
+		return yaml.NewLoader(yaml.WithStrict())
+	}
+	return yaml.NewLoader()
+}
+
+// Result is the result of Load.
+type Result struct {
+	// Tables is the loaded tables in the order they are written, parents first.
+	Tables []*TableResult
+}
+
+// TableResult is the result of loading a table.
+type TableResult struct {
+	// Name is the table name.
+	Name string
+	// Rows is the number of rows written to the table. Rows overridden by later rows of the same primary key
+	// with `duplicate_keys: override` are counted once.
+	Rows int
+}
+
+// Load writes the rows in the yaml files of fsys into the database of client.
+// Each file must be named `<Table Name>.yaml` and contain a list of rows.
+//
+// The returned error is a *FixtureError if the yaml files are invalid, or a *SaveError if writing to the database fails.
+// The client is not closed.
+func Load(ctx context.Context, client *spanner.Client, fsys fs.FS, opts ...Option) (*Result, error) {
+	o := newOptions(opts)
+
+	tables, err := o.loader().LoadFS(ctx, fsys, o.dir)
+	if err != nil {
+		return nil, &FixtureError{Err: err}
+	}
+
+	db := spannerdb.NewDBWithClient(client)
+	if err := db.Save(ctx, tables); err != nil {
+		return nil, &SaveError{Err: err}
+	}
+
+	return newResult(tables), nil
+}
+
+// UndoFunc reverts the rows written by LoadWithUndo.
+type UndoFunc func(ctx context.Context) error
+
+// LoadWithUndo is the same as Load, but it also returns a function which reverts exactly the rows written by the load:
+// the inserted rows are deleted and the overwritten rows are restored to the values before the load.
+// Rows of other keys are left as they are, so that loads of different keys don't affect each other.
+func LoadWithUndo(ctx context.Context, client *spanner.Client, fsys fs.FS, opts ...Option) (*Result, UndoFunc, error) {
+	o := newOptions(opts)
+
+	tables, err := o.loader().LoadFS(ctx, fsys, o.dir)
+	if err != nil {
+		return nil, nil, &FixtureError{Err: err}
+	}
+
+	db := spannerdb.NewDBWithClient(client)
+
+	snapshot, err := db.SnapshotRecords(ctx, tables)
+	if err != nil {
+		return nil, nil, &SaveError{Err: err}
+	}
+
+	if err := db.Save(ctx, tables); err != nil {
+		return nil, nil, &SaveError{Err: err}
+	}
+
+	if err := db.AddRecordKeys(ctx, snapshot, tables); err != nil {
+		return nil, nil, &SaveError{Err: err}
+	}
+
+	undo := func(ctx context.Context) error {
+		if err := db.Restore(ctx, snapshot); err != nil {
+			return &SaveError{Err: err}
+		}
+		return nil
+	}
+
+	return newResult(tables), undo, nil
+}
+
+func newResult(tables []*model.Table) *Result {
+	result := new(Result)
+	tableResults := make(map[string]*TableResult)
+	for _, t := range tables {
+		tr, ok := tableResults[t.Name]
+		if !ok {
+			tr = &TableResult{Name: t.Name}
+			tableResults[t.Name] = tr
+			result.Tables = append(result.Tables, tr)
+		}
+		tr.Rows += len(t.Records)
+	}
+
+	return result
+}
@@ -0,0 +1,112 @@
+package splanter
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"cloud.google.com/go/spanner"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	client := testClient(t, ctx)
+
+	fsys := fstest.MapFS{
+		// The row of the same key overrides the first one, and is counted once.
+		"seeds/_splanter.yaml": &fstest.MapFile{Data: []byte("duplicate_keys: override\n")},
+		"seeds/Foo.yaml":       &fstest.MapFile{Data: []byte("- FooID: 7d0b6c9e-1f2a-4b3c-8d4e-5f6a7b8c9d01\n  Name: foo0\n- FooID: 7d0b6c9e-1f2a-4b3c-8d4e-5f6a7b8c9d01\n  Name: foo1\n")},
+		"seeds/Bar.yaml":       &fstest.MapFile{Data: []byte("- FooID: 7d0b6c9e-1f2a-4b3c-8d4e-5f6a7b8c9d01\n  BarID: 1e2f3a4b-5c6d-4e7f-8a9b-0c1d2e3f4a52\n  Name: bar1\n- FooID: 7d0b6c9e-1f2a-4b3c-8d4e-5f6a7b8c9d01\n  BarID: 9a8b7c6d-5e4f-4a3b-2c1d-0e9f8a7b6c53\n  Name: bar2\n")},
+	}
+
+	result, err := Load(ctx, client, fsys, WithDirectory("seeds"))
+	if err != nil {
+		t.Fatalf("failed to load: %s", err)
+	}
+
+	expected := &Result{
+		Tables: []*TableResult{
+			{Name: "Foo", Rows: 1},
+			{Name: "Bar", Rows: 2},
+		},
+	}
+	if diff := cmp.Diff(result, expected); diff != "" {
+		t.Errorf("\n(-actual, +expected)\n%s", diff)
+	}
+
+	row, err := client.Single().ReadRow(ctx, "Bar", spanner.Key{"7d0b6c9e-1f2a-4b3c-8d4e-5f6a7b8c9d01", "9a8b7c6d-5e4f-4a3b-2c1d-0e9f8a7b6c53"}, []string{"Name"})
+	if err != nil {
+		t.Fatalf("failed to read Bar: %s", err)
+	}
+
+	var name string
+	if err := row.Columns(&name); err != nil {
+		t.Fatalf("failed to decode Bar: %s", err)
+	}
+	if name != "bar2" {
+		t.Errorf("expected bar2, but got %s", name)
+	}
+
+	// The client given to Load is still usable since it is not closed.
+	if _, err := client.Single().ReadRow(ctx, "Foo", spanner.Key{"7d0b6c9e-1f2a-4b3c-8d4e-5f6a7b8c9d01"}, []string{"Name"}); err != nil {
+		t.Errorf("failed to read Foo after Load: %s", err)
+	}
+}
+
+func TestLoadFixtureError(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	fsys := fstest.MapFS{
+		"seeds/Foo.yaml": &fstest.MapFile{Data: []byte("FooID: not a list\n")},
+	}
+
+	_, err := Load(ctx, nil, fsys, WithDirectory("seeds"))
+
+	var fixtureErr *FixtureError
+	if !errors.As(err, &fixtureErr) {
+		t.Errorf("expected *FixtureError, but got %v", err)
+	}
+}
+
+func TestLoadSaveError(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	client := testClient(t, ctx)
+
+	fsys := fstest.MapFS{
+		"NotFound.yaml": &fstest.MapFile{Data: []byte("- ID: 1\n")},
+	}
+
+	_, err := Load(ctx, client, fsys)
+
+	var saveErr *SaveError
+	if !errors.As(err, &saveErr) {
+		t.Errorf("expected *SaveError, but got %v", err)
+	}
+}
+
+func testClient(t *testing.T, ctx context.Context) *spanner.Client {
+	t.Helper()
+
+	project := os.Getenv("SPANNER_PROJECT")
+	instance := os.Getenv("SPANNER_INSTANCE")
+	database := os.Getenv("SPANNER_DATABASE")
+	if project == "" || instance == "" || database == "" {
+		t.Fatal("must specify SPANNER_PROJECT, SPANNER_INSTANCE and SPANNER_DATABASE")
+	}
+
+	client, err := spanner.NewClient(ctx, "projects/"+project+"/instances/"+instance+"/databases/"+database)
+	if err != nil {
+		t.Fatalf("failed to create spanner client: %s", err)
+	}
+	t.Cleanup(client.Close)
+
+	return client
+}
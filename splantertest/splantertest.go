@@ -0,0 +1,34 @@
+// Package splantertest provides helpers to seed Google Cloud Spanner databases in tests.
+package splantertest
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+
+	"github.com/kauche/splanter/splanter"
+)
+
+// Seed loads the yaml files in dir into the database of client, and registers a cleanup
+// which deletes exactly the inserted rows and restores the overwritten rows when the test finishes.
+// Tests seeding different keys can run in parallel without leaking data into each other.
+func Seed(t testing.TB, client *spanner.Client, dir string, opts ...splanter.Option) *splanter.Result {
+	t.Helper()
+
+	ctx := context.Background()
+
+	result, undo, err := splanter.LoadWithUndo(ctx, client, os.DirFS(dir), opts...)
+	if err != nil {
+		t.Fatalf("failed to seed %s: %s", dir, err)
+	}
+
+	t.Cleanup(func() {
+		if err := undo(ctx); err != nil {
+			t.Errorf("failed to clean up the seeds of %s: %s", dir, err)
+		}
+	})
+
+	return result
+}
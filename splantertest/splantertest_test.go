@@ -0,0 +1,86 @@
+package splantertest
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	fooID = "3c1f8a2e-6b4d-4e9a-9f7c-2d5e8b1a4c60"
+	barID = "8e2d4b6a-1c3f-4a5e-b7d9-0f2a4c6e8b71"
+)
+
+func TestSeed(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	client := testClient(t, ctx)
+
+	_, err := client.Apply(ctx, []*spanner.Mutation{spanner.InsertOrUpdate("Foo", []string{"FooID", "Name"}, []interface{}{fooID, "before"})})
+	if err != nil {
+		t.Fatalf("failed to write Foo: %s", err)
+	}
+
+	t.Run("seed", func(t *testing.T) {
+		result := Seed(t, client, "testdata/seeds")
+		if len(result.Tables) != 2 {
+			t.Errorf("expected 2 tables, but got %+v", result.Tables)
+		}
+
+		if name := readName(t, ctx, client, "Foo", spanner.Key{fooID}); name != "seeded" {
+			t.Errorf("expected the seeded Foo, but got %s", name)
+		}
+		// The FooID of Bar is filled by the rule in _splanter.yaml.
+		if name := readName(t, ctx, client, "Bar", spanner.Key{fooID, barID}); name != "seeded" {
+			t.Errorf("expected the seeded Bar, but got %s", name)
+		}
+	})
+
+	// The cleanup restores the overwritten row and deletes the inserted row.
+	if name := readName(t, ctx, client, "Foo", spanner.Key{fooID}); name != "before" {
+		t.Errorf("expected the restored Foo, but got %s", name)
+	}
+
+	_, err = client.Single().ReadRow(ctx, "Bar", spanner.Key{fooID, barID}, []string{"Name"})
+	if spanner.ErrCode(err) != codes.NotFound {
+		t.Errorf("expected the seeded Bar to be deleted, but got %v", err)
+	}
+}
+
+func readName(t *testing.T, ctx context.Context, client *spanner.Client, table string, key spanner.Key) string {
+	t.Helper()
+
+	row, err := client.Single().ReadRow(ctx, table, key, []string{"Name"})
+	if err != nil {
+		t.Fatalf("failed to read %s: %s", table, err)
+	}
+
+	var name string
+	if err := row.Columns(&name); err != nil {
+		t.Fatalf("failed to decode %s: %s", table, err)
+	}
+	return name
+}
+
+func testClient(t *testing.T, ctx context.Context) *spanner.Client {
+	t.Helper()
+
+	project := os.Getenv("SPANNER_PROJECT")
+	instance := os.Getenv("SPANNER_INSTANCE")
+	database := os.Getenv("SPANNER_DATABASE")
+	if project == "" || instance == "" || database == "" {
+		t.Fatal("must specify SPANNER_PROJECT, SPANNER_INSTANCE and SPANNER_DATABASE")
+	}
+
+	client, err := spanner.NewClient(ctx, "projects/"+project+"/instances/"+instance+"/databases/"+database)
+	if err != nil {
+		t.Fatalf("failed to create spanner client: %s", err)
+	}
+	t.Cleanup(client.Close)
+
+	return client
+}